@@ -0,0 +1,40 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// runDump walks the input directory and writes every annotation instance to stdout.
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	dir := fs.String("dir", "./pkg", "directory of Go files to dump annotations from")
+	format := fs.String("o", "json", "output format, json or yaml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	idx, err := annotation.ScanByDir(*dir, registry())
+	if err != nil {
+		return err
+	}
+	return annotation.Dump(os.Stdout, idx.Instances(), *format)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fanzhangio/go-annotation/pkg/codegen/parse"
+)
+
+// runExplain parses API resources of the apis directory, and explains CRD of the type named by the argument,
+// e.g. "go-annotation explain v1.Frigate", by annotations of the type and its fields. Options of loading API
+// resources are defaults, overridden by the project file, and then by flags.
+func runExplain(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	format := fs.String("o", "text", "output format, text, json or yaml")
+	o, err := apisOptions(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("type is required, e.g. go-annotation explain v1.Frigate")
+	}
+	apis, err := o.Load()
+	if err != nil {
+		return err
+	}
+	e, err := apis.Explain(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return e.Write(os.Stdout, *format)
+}
+
+// apisOptions registers flags of loading API resources in fs and parses args by it. Options are resolved from
+// defaults, the project file and flags.
+func apisOptions(fs *flag.FlagSet, args []string) (*parse.Options, error) {
+	dir := fs.String("apis-dir", "", "directory of API packages, which are loaded recursively")
+	domain := fs.String("domain", "", "domain of API groups, read from +domain of doc.go of the apis package if empty")
+	features := fs.String("features", "", "feature flags enabled for conditional annotations, split by comma")
+	strict := fs.Bool("strict", true, "report annotations of unregistered modules under registered headers as errors")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	o := &parse.Options{}
+	o.SetDefaults()
+	if err := o.ApplyConfig(project); err != nil {
+		return nil, err
+	}
+	override(&o.APIsDir, *dir)
+	override(&o.Domain, *domain)
+	overrideList(&o.Features, *features)
+	overrideBool(fs, &o.Strict, "strict", *strict)
+	return o, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/crd"
+	"github.com/fanzhangio/go-annotation/pkg/diff"
+	"github.com/fanzhangio/go-annotation/pkg/rbac"
+	"github.com/fanzhangio/go-annotation/pkg/webhook"
+)
+
+// runGenerate runs generator of given name. Options are defaults of the generator, overridden by
+// the project file, and then by flags. In dry run, diffs of generated files against files on disk are printed
+// instead of writing them, and it fails if any file differs.
+func runGenerate(args []string) error {
+	project.Apply(annotation.GetAnnotation())
+	g, err := newGeneration(args)
+	if err != nil {
+		return err
+	}
+	if !g.dryRun {
+		return g.run()
+	}
+	files, err := diff.DryRun(g.outputDirs, g.run)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Print(f.Diff)
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("%d generated files differ from files on disk", len(files))
+	}
+	return nil
+}
+
+// generation is a generator with its options resolved
+type generation struct {
+	name string
+	// modules are modules of annotations the generator handles
+	modules    []string
+	inputDir   string
+	outputDirs []string
+	dryRun     bool
+	run        func() error
+	// inputs returns hash of inputs recorded in headers of generated files
+	inputs func() (string, error)
+}
+
+// newGeneration resolves options of generator named by the first argument from defaults, the project file and flags
+func newGeneration(args []string) (*generation, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("generator is required, one of rbac, webhook or crd")
+	}
+	name, args := args[0], args[1:]
+	fs := flag.NewFlagSet("generate "+name, flag.ExitOnError)
+	inputDir := fs.String("input-dir", "", "directory of Go files to parse annotations from")
+	outputDir := fs.String("output-dir", "", "directory generated files are written to")
+	features := fs.String("features", "", "feature flags enabled for conditional annotations, split by comma")
+	dryRun := fs.Bool("dry-run", false, "print diffs of generated files against files on disk without writing them, fail if they differ")
+
+	switch name {
+	case "rbac":
+		roleName := fs.String("name", "", "name of the role")
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		o := &rbac.ManifestOptions{}
+		o.SetDefaults()
+		if err := o.ApplyConfig(project); err != nil {
+			return nil, err
+		}
+		override(&o.InputDir, *inputDir)
+		override(&o.OutputDir, *outputDir)
+		override(&o.Name, *roleName)
+		overrideList(&o.Features, *features)
+		return &generation{name: name, modules: []string{"rbac"}, inputDir: o.InputDir, outputDirs: []string{o.OutputDir}, dryRun: *dryRun,
+			run: func() error { return rbac.Generate(o) }, inputs: o.Inputs}, nil
+	case "webhook":
+		patchOutputDir := fs.String("patch-output-dir", "", "directory of the label patch of manager")
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		o := &webhook.ManifestOptions{}
+		o.SetDefaults()
+		if err := o.ApplyConfig(project); err != nil {
+			return nil, err
+		}
+		override(&o.InputDir, *inputDir)
+		override(&o.OutputDir, *outputDir)
+		override(&o.PatchOutputDir, *patchOutputDir)
+		overrideList(&o.Features, *features)
+		return &generation{name: name, modules: []string{"webhook"}, inputDir: o.InputDir, outputDirs: []string{o.OutputDir, o.PatchOutputDir}, dryRun: *dryRun,
+			run: func() error { return webhook.Generate(o) }, inputs: o.Inputs}, nil
+	case "crd":
+		domain := fs.String("domain", "", "domain of API groups, read from +domain of doc.go of the apis package if empty")
+		strict := fs.Bool("strict", true, "report annotations of unregistered modules under registered headers as errors")
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		o := &crd.ManifestOptions{}
+		o.SetDefaults()
+		if err := o.ApplyConfig(project); err != nil {
+			return nil, err
+		}
+		override(&o.APIsDir, *inputDir)
+		override(&o.OutputDir, *outputDir)
+		override(&o.Domain, *domain)
+		overrideList(&o.Features, *features)
+		overrideBool(fs, &o.Strict, "strict", *strict)
+		return &generation{name: name, modules: crd.Modules(), inputDir: o.APIsDir, outputDirs: []string{o.OutputDir}, dryRun: *dryRun,
+			run: func() error { return crd.Generate(o) }, inputs: o.Inputs}, nil
+	}
+	return nil, fmt.Errorf("unknown generator %q, expect rbac, webhook or crd", name)
+}
+
+// override sets option by value of flag if the flag is set
+func override(option *string, flag string) {
+	if len(flag) > 0 {
+		*option = flag
+	}
+}
+
+// overrideList sets option by comma separated values of flag if the flag is set
+func overrideList(option *[]string, flag string) {
+	if len(flag) > 0 {
+		*option = strings.Split(flag, ",")
+	}
+}
+
+// overrideBool sets option by value of flag of given name if the flag is set
+func overrideBool(fs *flag.FlagSet, option *bool, name string, value bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			*option = value
+		}
+	})
+}
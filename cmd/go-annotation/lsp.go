@@ -0,0 +1,39 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/fanzhangio/go-annotation/pkg/lsp"
+)
+
+// runLSP serves language server of annotations on stdin and stdout.
+func runLSP(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return serveLSP(os.Stdin, os.Stdout)
+}
+
+// serveLSP serves language server of annotations of registry on r and w
+func serveLSP(r io.Reader, w io.Writer) error {
+	return lsp.NewServer(registry).Serve(r, w)
+}
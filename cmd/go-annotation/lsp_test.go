@@ -0,0 +1,59 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestServeLSP(t *testing.T) {
+	var in bytes.Buffer
+	for _, msg := range []map[string]interface{}{
+		{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": map[string]interface{}{}},
+		{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///foo.go", "text": "package foo\n\n// +rbac:groups=apps,verbs=get\ntype Foo struct{}\n"},
+		}},
+		{"jsonrpc": "2.0", "id": 2, "method": "textDocument/codeAction", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///foo.go"},
+			"range":        map[string]interface{}{"start": map[string]int{"line": 2}, "end": map[string]int{"line": 2}},
+		}},
+		{"jsonrpc": "2.0", "method": "exit"},
+	} {
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	}
+	var out bytes.Buffer
+	if err := serveLSP(&in, &out); err != nil {
+		t.Fatalf("serveLSP should have succeeded, but got error: %v", err)
+	}
+	// deprecations of default annotation are registered by registry
+	for _, exp := range []string{
+		`"message":"deprecated spelling, use +kubebuilder:rbac:groups=apps,verbs=get"`,
+		`"newText":"+kubebuilder:rbac:groups=apps,verbs=get"`,
+	} {
+		if !strings.Contains(out.String(), exp) {
+			t.Errorf("expect output containing %s, got\n%s", exp, out.String())
+		}
+	}
+}
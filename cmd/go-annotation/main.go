@@ -0,0 +1,94 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// go-annotation is command line tool working on annotations of Go source files.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/config"
+)
+
+// command is a subcommand of go-annotation, which takes the arguments after its name.
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"dump":     {usage: "dump annotations of Go files as JSON or YAML", run: runDump},
+	"explain":  {usage: "explain CRD of API resource type by its annotations, e.g. explain v1.Frigate", run: runExplain},
+	"generate": {usage: "generate manifests by generator rbac, webhook or crd", run: runGenerate},
+	"lsp":      {usage: "serve language server of annotations on stdin and stdout", run: runLSP},
+	"report":   {usage: "report modules never invoked and annotations without effect", run: runReport},
+	"schema":   {usage: "export JSON Schema of registered headers, modules and params", run: runSchema},
+	"verify":   {usage: "verify files of generators are up to date, fail if any is stale", run: runVerify},
+	"watch":    {usage: "rerun generators on changes of annotations of their modules", run: runWatch},
+}
+
+// project is the project file loaded by -config flag
+var project = &config.Config{}
+
+func main() {
+	flag.Usage = usage
+	verbosity := flag.Int("v", 0, "verbosity of logs written to stderr, logs are discarded if 0")
+	configFile := flag.String("config", config.DefaultFile, "project file, the default one is ignored if it does not exist")
+	flag.Parse()
+	if *verbosity > 0 {
+		annotation.SetLogger(annotation.NewLogger(os.Stderr, *verbosity))
+	}
+	var err error
+	if *configFile == config.DefaultFile {
+		project, err = config.LoadDefault()
+	} else {
+		project, err = config.Load(*configFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: go-annotation [-v level] [-config file] <command> [flags]\n\nCommands:\n")
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
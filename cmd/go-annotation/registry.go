@@ -0,0 +1,39 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/codegen/parse"
+	"github.com/fanzhangio/go-annotation/pkg/rbac"
+	"github.com/fanzhangio/go-annotation/pkg/webhook"
+)
+
+// registry returns new annotation with default headers and deprecations and all modules of the generators
+// registered, headers, modules and strictness of project file are applied.
+// Module handlers only collect results in the returned annotation, nothing is generated.
+func registry() annotation.Annotation {
+	a := annotation.AddDefaults(annotation.Build())
+	project.Apply(a)
+	a.Intercept(annotation.Logging(nil))
+	parse.AddToAnnotation(a)
+	rbac.AddToAnnotation(a)
+	o := &webhook.ManifestOptions{}
+	o.SetDefaults()
+	o.AddToAnnotation(a)
+	return a
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// runReport parses the input directory and reports modules never invoked, annotations without effect
+// and annotations matching no header or module.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	dir := fs.String("dir", "./pkg", "directory of Go files to report annotations of")
+	format := fs.String("o", "text", "output format, text, json or yaml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	a := registry()
+	// annotations of unknown modules are reported instead of failing the run
+	a.Strict(false)
+	u := annotation.NewUsage()
+	a.Intercept(u.Intercept())
+	idx, err := annotation.IndexByDir(*dir, a)
+	if err != nil {
+		return err
+	}
+	return u.Report(a, idx).Write(os.Stdout, *format)
+}
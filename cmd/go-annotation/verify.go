@@ -0,0 +1,118 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/diff"
+)
+
+// runVerify checks files of generators are up to date. Generators run in memory, and generated files differing from
+// files on disk are stale. With -quick, nothing is generated, and files whose headers record other version or inputs
+// than the current ones are stale.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	generators := fs.String("generators", "rbac,webhook,crd", "generators to verify, split by comma")
+	quick := fs.Bool("quick", false, "compare headers of generated files with hash of current inputs instead of generating")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	project.Apply(annotation.GetAnnotation())
+
+	stale := 0
+	for _, name := range strings.Split(*generators, ",") {
+		g, err := newGeneration([]string{name})
+		if err != nil {
+			return err
+		}
+		var files []string
+		if *quick {
+			files, err = staleHeaders(g)
+		} else {
+			files, err = staleFiles(g)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		for _, f := range files {
+			fmt.Printf("stale: %s\n", f)
+		}
+		if len(files) > 0 {
+			fmt.Printf("run \"go-annotation generate %s\" to update the files of %s\n", name, name)
+		}
+		stale += len(files)
+	}
+	if stale > 0 {
+		return fmt.Errorf("%d generated files are stale", stale)
+	}
+	return nil
+}
+
+// staleFiles returns generated files differing from files on disk
+func staleFiles(g *generation) ([]string, error) {
+	files, err := diff.DryRun(g.outputDirs, g.run)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	return paths, nil
+}
+
+// staleHeaders returns files in output directories whose headers record other version or inputs than the current
+// ones. Files without header are not checked, so missing files are found by generating only.
+func staleHeaders(g *generation) ([]string, error) {
+	inputs, err := g.inputs()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, dir := range g.outputDirs {
+		infos, err := afero.ReadDir(annotation.Fs(), dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, info.Name())
+			content, err := afero.ReadFile(annotation.Fs(), path)
+			if err != nil {
+				return nil, err
+			}
+			version, recorded, ok := annotation.ReadGeneratedHeader(content)
+			if ok && (version != annotation.Version || recorded != inputs) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/watch"
+)
+
+// runWatch runs generators, and reruns them whenever annotations of their modules change in their input directories.
+// Only changed files are re-scanned, and a summary of changed annotations and manifests is printed for every change.
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	generators := fs.String("generators", "rbac,webhook", "generators to rerun on changes, split by comma")
+	debounce := fs.Duration("debounce", watch.DefaultDebounce, "quiet period after the last change before regenerating")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	project.Apply(annotation.GetAnnotation())
+
+	gens := []*generation{}
+	dirs := []string{}
+	for _, name := range strings.Split(*generators, ",") {
+		g, err := newGeneration([]string{name})
+		if err != nil {
+			return err
+		}
+		gens = append(gens, g)
+		if !contains(dirs, g.inputDir) {
+			dirs = append(dirs, g.inputDir)
+		}
+	}
+
+	s := watch.NewSession(registry())
+	if err := s.Load(dirs...); err != nil {
+		return err
+	}
+	w, err := watch.NewWatcher(dirs...)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	w.Debounce = *debounce
+	w.Files = s.Parsed
+
+	for _, g := range gens {
+		regenerate(g)
+	}
+	fmt.Printf("watching %s\n", strings.Join(dirs, ", "))
+	for {
+		paths, err := w.Next()
+		if err != nil {
+			return err
+		}
+		c := s.Update(paths)
+		if len(c.Files) == 0 {
+			continue
+		}
+		fmt.Print(c.Summary())
+		for _, g := range gens {
+			if c.Affects(g.modules...) {
+				regenerate(g)
+			}
+		}
+	}
+}
+
+// regenerate runs generator and prints files of its output directories created, updated or removed.
+// Errors are printed rather than returned, so that watching goes on until the source is fixed. Generation is not
+// incremental: the generator re-parses its whole input by a registry of its own, results of the session only decide
+// whether it reruns.
+func regenerate(g *generation) {
+	before := snapshot(g.outputDirs)
+	if err := g.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", g.name, err)
+		return
+	}
+	after := snapshot(g.outputDirs)
+
+	changes := []string{}
+	for path, content := range after {
+		if old, ok := before[path]; !ok {
+			changes = append(changes, "created "+path)
+		} else if !bytes.Equal(old, content) {
+			changes = append(changes, "updated "+path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, "removed "+path)
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Printf("%s: no manifest changed\n", g.name)
+		return
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return strings.SplitN(changes[i], " ", 2)[1] < strings.SplitN(changes[j], " ", 2)[1]
+	})
+	for _, change := range changes {
+		fmt.Printf("%s: %s\n", g.name, change)
+	}
+}
+
+// snapshot returns contents of files in given directories, directories not existing are skipped
+func snapshot(dirs []string) map[string][]byte {
+	files := map[string][]byte{}
+	for _, dir := range dirs {
+		infos, err := afero.ReadDir(annotation.Fs(), dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			if info.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, info.Name())
+			if content, err := afero.ReadFile(annotation.Fs(), path); err == nil {
+				files[path] = content
+			}
+		}
+	}
+	return files
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package annotation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Adapter rewrites annotations written for other tools into annotations of registered modules, so code annotated
+// for either tool is parsed by the same registry. Lines are adapted as they are read, before constants, conditions
+// and macros are handled, and adapted annotations are positioned at their lines.
+type Adapter interface {
+	// Adapt returns annotations given line is rewritten into, and true if the line is recognized. Recognized line
+	// rewritten into no annotation is dropped. Error is returned if the line has no counterpart in registered modules.
+	Adapt(line string) ([]string, bool, error)
+}
+
+func (a *defaultAnnotation) Adapt(adapter Adapter) {
+	a.adapters = append(a.adapters, adapter)
+}
+
+// Adapted returns annotations of the first adapter recognizing given line. Adapted annotations are not adapted again.
+func (a *defaultAnnotation) Adapted(line string) ([]string, error) {
+	for _, adapter := range a.adapters {
+		lines, ok, err := adapter.Adapt(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return lines, nil
+		}
+	}
+	return []string{line}, nil
+}
+
+// controllerGenPrefix is the prefix of controller-gen markers
+const controllerGenPrefix = "+kubebuilder:"
+
+// ControllerGenAdapter adapts markers of controller-gen onto modules of kubebuilder header, e.g.
+//
+//	+kubebuilder:webhook:path=/mutate-v1-foo,mutating=true,failurePolicy=fail,groups=ship,resources=foos,verbs=create
+//	+kubebuilder:resource:path=foos,scope=Cluster,categories=all;ship
+//	+kubebuilder:validation:Enum=Foo;Bar
+//
+// are adapted into
+//
+//	+kubebuilder:webhook:admission:path=/mutate-v1-foo,type=mutating,failure-policy=fail,groups=ship,resources=foos,verbs=create
+//	+kubebuilder:resource:path=foos
+//	+genclient:nonNamespaced
+//	+kubebuilder:categories:all,ship
+//	+kubebuilder:validation:Enum=Foo,Bar
+//
+// Markers of the same spelling in both tools, e.g. +kubebuilder:rbac or +kubebuilder:printcolumn, are not recognized
+// and are parsed as they are. Markers without effect on generation here, e.g. +kubebuilder:object:root, are dropped.
+// Condition element, see ConditionKey, is kept on every adapted annotation.
+type ControllerGenAdapter struct{}
+
+// Adapt rewrites controller-gen marker of given line, see ControllerGenAdapter
+func (ControllerGenAdapter) Adapt(line string) ([]string, bool, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, controllerGenPrefix) {
+		return nil, false, nil
+	}
+	tokens := strings.SplitN(strings.TrimPrefix(line, controllerGenPrefix), ":", 2)
+	var elements string
+	if len(tokens) == 2 {
+		elements = tokens[1]
+	}
+	switch tokens[0] {
+	case "object", "storageversion":
+		Log().V(2).Info("dropped controller-gen marker without effect on generation", "marker", line)
+		return []string{}, true, nil
+	case "webhook":
+		if key := strings.SplitN(elements, "=", 2)[0]; strings.Contains(key, ":") || !strings.Contains(elements, "=") {
+			// submodule of webhook, e.g. +kubebuilder:webhook:admission:...
+			return nil, false, nil
+		}
+		return adaptMarker(line, elements, adaptWebhook)
+	case "resource":
+		return adaptMarker(line, elements, adaptResource)
+	case "rbac":
+		return adaptMarker(line, elements, adaptRBAC)
+	case "validation":
+		switch elements {
+		case "Optional", "Required":
+			return nil, true, fmt.Errorf("controller-gen marker %s is not supported, "+
+				"fields are required unless their json tags have omitempty", line)
+		}
+		if strings.HasPrefix(elements, "Enum=") {
+			return []string{controllerGenPrefix + "validation:" + strings.Replace(elements, ";", ",", -1)}, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// markerElements are key-value elements of controller-gen marker adapted into annotation named by prefix, e.g.
+// "+kubebuilder:webhook:admission:", and other annotations the marker implies
+type markerElements struct {
+	prefix   string
+	elements []string
+	implied  []string
+}
+
+// adaptMarker adapts elements of marker by given function, which returns false for elements spelled the same in
+// both tools. Marker is recognized if any of its elements is adapted. Condition element of marker is appended to
+// every annotation adapted.
+func adaptMarker(line, elements string, adapt func(key, value string, m *markerElements) (bool, error)) ([]string, bool, error) {
+	m := &markerElements{}
+	var condition string
+	recognized := false
+	for _, elem := range strings.Split(elements, ",") {
+		key, value, err := ParseKV(elem)
+		if err != nil {
+			return nil, false, nil
+		}
+		if key == ConditionKey {
+			condition = elem
+			continue
+		}
+		ok, err := adapt(key, value, m)
+		if err != nil {
+			return nil, true, fmt.Errorf("controller-gen marker %s: %v", line, err)
+		}
+		recognized = recognized || ok
+	}
+	if !recognized {
+		return nil, false, nil
+	}
+	lines := []string{}
+	if len(m.elements) > 0 {
+		lines = append(lines, m.prefix+strings.Join(m.elements, ","))
+	}
+	lines = append(lines, m.implied...)
+	if len(condition) > 0 {
+		for n, l := range lines {
+			separator := ","
+			if strings.HasPrefix(l, "+genclient:") {
+				separator = ":"
+			}
+			lines[n] = l + separator + condition
+		}
+	}
+	return lines, true, nil
+}
+
+// adaptWebhook adapts element of +kubebuilder:webhook marker into +kubebuilder:webhook:admission annotation.
+// Elements required by controller-gen for v1 manifests only are dropped.
+func adaptWebhook(key, value string, m *markerElements) (bool, error) {
+	m.prefix = controllerGenPrefix + "webhook:admission:"
+	switch key {
+	case "path", "groups", "versions", "resources", "verbs", "name":
+		m.elements = append(m.elements, key+"="+value)
+	case "mutating":
+		mutating, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid value %s of mutating", value)
+		}
+		if mutating {
+			m.elements = append(m.elements, "type=mutating")
+		} else {
+			m.elements = append(m.elements, "type=validating")
+		}
+	case "failurePolicy":
+		m.elements = append(m.elements, "failure-policy="+strings.ToLower(value))
+	case "sideEffects", "admissionReviewVersions", "webhookVersions":
+	default:
+		return false, fmt.Errorf("%s is not supported", key)
+	}
+	return true, nil
+}
+
+// adaptResource adapts element of +kubebuilder:resource marker, scope and categories are declared by
+// +genclient:nonNamespaced and +kubebuilder:categories here
+func adaptResource(key, value string, m *markerElements) (bool, error) {
+	m.prefix = controllerGenPrefix + "resource:"
+	switch key {
+	case "path":
+		m.elements = append(m.elements, key+"="+value)
+		return false, nil
+	case "shortName":
+		if strings.Contains(value, ";") {
+			return false, fmt.Errorf("multiple short names %s are not supported", value)
+		}
+		m.elements = append(m.elements, key+"="+value)
+		return false, nil
+	case "scope":
+		switch value {
+		case "Cluster":
+			m.implied = append(m.implied, "+genclient:nonNamespaced")
+		case "Namespaced":
+		default:
+			return false, fmt.Errorf("unknown scope %s", value)
+		}
+	case "categories":
+		m.implied = append(m.implied, controllerGenPrefix+"categories:"+strings.Replace(value, ";", ",", -1))
+	default:
+		return false, fmt.Errorf("%s is not supported", key)
+	}
+	return true, nil
+}
+
+// adaptRBAC rejects elements of +kubebuilder:rbac marker not supported by rbac module, rules are granted by
+// ClusterRole here, and other elements are spelled the same
+func adaptRBAC(key, _ string, _ *markerElements) (bool, error) {
+	switch key {
+	case "namespace", "resourceNames":
+		return false, fmt.Errorf("%s is not supported", key)
+	}
+	return false, nil
+}
@@ -0,0 +1,162 @@
+package annotation
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestControllerGenAdapter(t *testing.T) {
+	tests := []struct {
+		line string
+		exp  []string
+		ok   bool
+		err  string
+	}{
+		{
+			line: "+kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=Fail,sideEffects=None,groups=core,resources=pods,verbs=create;update,versions=v1,name=mpod.kb.io,admissionReviewVersions=v1",
+			exp:  []string{"+kubebuilder:webhook:admission:path=/mutate-v1-pod,type=mutating,failure-policy=fail,groups=core,resources=pods,verbs=create;update,versions=v1,name=mpod.kb.io"},
+			ok:   true,
+		},
+		{
+			line: "+kubebuilder:webhook:path=/validate,mutating=false,groups=ship,resources=foos,verbs=create,if=enterprise",
+			exp:  []string{"+kubebuilder:webhook:admission:path=/validate,type=validating,groups=ship,resources=foos,verbs=create,if=enterprise"},
+			ok:   true,
+		},
+		{
+			line: "+kubebuilder:webhook:admission:groups=apps,resources=deployments,type=mutating",
+		},
+		{
+			line: "+kubebuilder:webhook:path=/mutate,mutating=true,reinvocationPolicy=IfNeeded",
+			ok:   true,
+			err:  "reinvocationPolicy is not supported",
+		},
+		{
+			line: "+kubebuilder:resource:path=foos,shortName=fo,scope=Cluster,categories=all;ship",
+			exp:  []string{"+kubebuilder:resource:path=foos,shortName=fo", "+genclient:nonNamespaced", "+kubebuilder:categories:all,ship"},
+			ok:   true,
+		},
+		{
+			line: "+kubebuilder:resource:scope=Cluster,if=enterprise",
+			exp:  []string{"+genclient:nonNamespaced:if=enterprise"},
+			ok:   true,
+		},
+		{
+			line: "+kubebuilder:resource:path=foos,shortName=fo",
+		},
+		{
+			line: "+kubebuilder:resource:path=foos,shortName=fo;f",
+			ok:   true,
+			err:  "multiple short names fo;f are not supported",
+		},
+		{
+			line: "+kubebuilder:resource:path=foos,singular=foo",
+			ok:   true,
+			err:  "singular is not supported",
+		},
+		{
+			line: "+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list",
+		},
+		{
+			line: "+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get,namespace=system",
+			ok:   true,
+			err:  "namespace is not supported",
+		},
+		{
+			line: "+kubebuilder:validation:Enum=Foo;Bar",
+			exp:  []string{"+kubebuilder:validation:Enum=Foo,Bar"},
+			ok:   true,
+		},
+		{
+			line: "+kubebuilder:validation:Minimum=0",
+		},
+		{
+			line: "+kubebuilder:validation:Optional",
+			ok:   true,
+			err:  "fields are required unless their json tags have omitempty",
+		},
+		{
+			line: "+kubebuilder:object:root=true",
+			exp:  []string{},
+			ok:   true,
+		},
+		{
+			line: "+kubebuilder:subresource:status",
+		},
+		{
+			line: "Foo is a sample type",
+		},
+	}
+	for _, test := range tests {
+		lines, ok, err := ControllerGenAdapter{}.Adapt(test.line)
+		if len(test.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expect error of %s containing %q, got %v", test.line, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error of %s: %v", test.line, err)
+			continue
+		}
+		if ok != test.ok || !reflect.DeepEqual(lines, test.exp) {
+			t.Errorf("expect %s adapted into %v (%v), got %v (%v)", test.line, test.exp, test.ok, lines, ok)
+		}
+	}
+}
+
+func TestAdapted(t *testing.T) {
+	ann := Build()
+	ann.Header("kubebuilder")
+	ann.Header("genclient")
+	handled := []string{}
+	for _, name := range []string{"resource", "categories", "nonNamespaced"} {
+		name := name
+		ann.Module(&Module{Name: name, Do: func(s string) error {
+			handled = append(handled, name+" "+s)
+			return nil
+		}})
+	}
+	ann.Adapt(ControllerGenAdapter{})
+
+	content := `package foo
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=foos,scope=Cluster,categories=all
+type Foo struct{}
+
+// +kubebuilder:resource:path=bars
+// +kubebuilder:categories:all
+type Bar struct{}
+`
+	idx := NewIndex()
+	if err := ScanByFile(token.NewFileSet(), "test.go", content, ann, idx); err != nil {
+		t.Fatalf("ScanByFile should have succeeded, but got error: %v", err)
+	}
+	got := []string{}
+	for _, i := range idx.Instances() {
+		got = append(got, fmt.Sprintf("%d:%d %s %s", i.Position.Line, i.Position.Column, i.Target, i.Text))
+	}
+	exp := []string{
+		"4:1 Foo +kubebuilder:resource:path=foos",
+		"4:1 Foo +genclient:nonNamespaced",
+		"4:1 Foo +kubebuilder:categories:all",
+		"7:1 Bar +kubebuilder:resource:path=bars",
+		"8:1 Bar +kubebuilder:categories:all",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expect adapted annotations positioned at markers %v, got %v", exp, got)
+	}
+	if err := ParseAnnotationByFile(token.NewFileSet(), "test.go", content, ann); err != nil {
+		t.Fatalf("ParseAnnotationByFile should have succeeded, but got error: %v", err)
+	}
+	if exp := []string{"resource path=foos", "nonNamespaced nonNamespaced", "categories all", "resource path=bars", "categories all"}; !reflect.DeepEqual(handled, exp) {
+		t.Errorf("expect handlers %v, got %v", exp, handled)
+	}
+
+	if lines, err := ann.Adapted("+kubebuilder:rbac:groups=apps,verbs=get,namespace=system"); err == nil {
+		t.Errorf("expect error of namespaced rule, got %v", lines)
+	}
+}
@@ -0,0 +1,174 @@
+package annotation
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// Cardinality declares how many times annotation of module may occur
+type Cardinality int
+
+const (
+	// Repeatable module may occur any number of times, e.g. +kubebuilder:printcolumn
+	Repeatable Cardinality = iota
+	// OncePerTarget module may occur once on each declaration, e.g. +kubebuilder:resource
+	OncePerTarget
+	// OncePerRun module may occur once in a run, e.g. +kubebuilder:webhook:serveroption
+	OncePerRun
+)
+
+// Merge declares how duplicate occurrences of module are merged. Identical duplicate of module
+// occurring once is always ignored.
+type Merge int
+
+const (
+	// MergeNone reports duplicate occurrence of module occurring once as conflict
+	MergeNone Merge = iota
+	// MergeKeys merges duplicate occurrences by element key, the same key with different values is conflict
+	MergeKeys
+	// MergeUnion drops elements already present on the same declaration (or in the run for OncePerRun),
+	// e.g. duplicate categories. Occurrence left with no elements is ignored
+	MergeUnion
+)
+
+// occurrence is annotation of module handled in current declaration or run
+type occurrence struct {
+	instance *Instance
+	pos      token.Position
+}
+
+// occurrences tracks handled annotations of modules by module path, for enforcing cardinality
+type occurrences struct {
+	target string
+	pkg    string
+	// byTarget is occurrences on current declaration, reset on entering every comment group
+	byTarget map[string][]occurrence
+	// byRun is occurrences in current run, reset on starting run
+	byRun map[string][]occurrence
+}
+
+func newOccurrences() *occurrences {
+	return &occurrences{byTarget: map[string][]occurrence{}, byRun: map[string][]occurrence{}}
+}
+
+func (o *occurrences) startRun() {
+	*o = *newOccurrences()
+}
+
+// enterGroup resets occurrences on declaration for comment group of given declaration, empty for comments of no
+// declaration, so annotations of the group are not checked against the previous declaration
+func (o *occurrences) enterGroup(t Target) {
+	o.target, o.pkg = t.Name, t.Package
+	o.byTarget = map[string][]occurrence{}
+}
+
+// enterGroup resets occurrences on declaration of a for comment group of given declaration, see occurrences.enterGroup
+func enterGroup(a Annotation, t Target) {
+	if d, ok := a.(*defaultAnnotation); ok {
+		d.occurrences.enterGroup(t)
+	}
+}
+
+// check enforces cardinality of module handling given annotation line at pos. It returns the line to handle,
+// which may have duplicate elements dropped, or false if the line should be ignored.
+func (o *occurrences) check(a Annotation, comment string, pos token.Position) (string, bool, error) {
+	i := a.Resolve(comment)
+	if i == nil {
+		return comment, true, nil
+	}
+	path := i.Path()
+	m := ResolveModule(a, path)
+	if m == nil || (m.Cardinality == Repeatable && m.Merge != MergeUnion) {
+		return comment, true, nil
+	}
+	seen := o.byTarget
+	if m.Cardinality == OncePerRun {
+		seen = o.byRun
+	}
+	prev := seen[path]
+	cur := occurrence{instance: i, pos: pos}
+	seen[path] = append(prev, cur)
+
+	if m.Merge == MergeUnion {
+		elements := []string{}
+		for n, e := range i.Elements {
+			if !hasElement(prev, e) && !containsElement(i.Elements[:n], e) {
+				elements = append(elements, elementString(e))
+			}
+		}
+		if len(elements) == 0 {
+			return comment, false, nil
+		}
+		return strings.TrimSuffix(i.Text, i.RawElements) + strings.Join(elements, ","), true, nil
+	}
+	if len(prev) == 0 {
+		return comment, true, nil
+	}
+	for _, p := range prev {
+		if p.instance.RawElements == i.RawElements {
+			return comment, false, nil
+		}
+	}
+	if m.Merge == MergeKeys {
+		for _, e := range i.Elements {
+			for _, p := range prev {
+				if v, ok := p.instance.Value(e.Key); ok && v != e.Value {
+					return "", false, fmt.Errorf("%sconflicting value of %s for module %s%s: %q here and %q at %s",
+						location(pos, ": "), e.Key, path, o.of(m), e.Value, v, location(p.pos, ""))
+				}
+			}
+		}
+		return comment, true, nil
+	}
+	scope := "declaration"
+	if m.Cardinality == OncePerRun {
+		scope = "run"
+	}
+	return "", false, fmt.Errorf("%smodule %s may occur once per %s%s: %q here and %q at %s",
+		location(pos, ": "), path, scope, o.of(m), i.Text, prev[0].instance.Text, location(prev[0].pos, ""))
+}
+
+// of names current declaration in error messages of module occurring once per declaration
+func (o *occurrences) of(m *Module) string {
+	if m.Cardinality == OncePerRun || len(o.target) == 0 {
+		return ""
+	}
+	return " of " + o.target
+}
+
+// location returns source position followed by suffix, or description of unknown position
+func location(pos token.Position, suffix string) string {
+	if !pos.IsValid() {
+		if len(suffix) > 0 {
+			return ""
+		}
+		return "a previous line"
+	}
+	return pos.String() + suffix
+}
+
+func hasElement(occurrences []occurrence, e Element) bool {
+	for _, o := range occurrences {
+		if containsElement(o.instance.Elements, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsElement(elements []Element, e Element) bool {
+	for _, p := range elements {
+		if p == e {
+			return true
+		}
+	}
+	return false
+}
+
+func elementString(e Element) string {
+	if len(e.Value) == 0 {
+		return e.Key
+	}
+	return e.Key + "=" + e.Value
+}
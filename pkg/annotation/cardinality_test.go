@@ -0,0 +1,127 @@
+package annotation
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestCardinality(t *testing.T) {
+	tests := []struct {
+		content string
+		exp     []string
+		err     string
+	}{
+		{
+			content: `
+	// +kubebuilder:resource:path=foos
+	// +kubebuilder:resource:shortName=fo
+	// +kubebuilder:resource:path=foos
+	type Foo struct{}
+
+	// +kubebuilder:resource:path=bars
+	type Bar struct{}
+	`,
+			exp: []string{"resource path=foos", "resource shortName=fo", "resource path=bars"},
+		},
+		{
+			content: `
+	// +kubebuilder:resource:path=foos
+	// +kubebuilder:resource:path=bars
+	type Foo struct{}
+	`,
+			err: `test.go:4:2: conflicting value of path for module resource of Foo: "bars" here and "foos" at test.go:3:2`,
+		},
+		{
+			content: `
+	// +kubebuilder:resource:path=foos
+	// +kubebuilder:nonNamespaced
+	type Foo struct{}
+
+	// +kubebuilder:resource:path=bars
+	// +kubebuilder:nonNamespaced
+	`,
+			exp: []string{"resource path=foos", "nonNamespaced nonNamespaced", "resource path=bars", "nonNamespaced nonNamespaced"},
+		},
+		{
+			content: `
+	// +kubebuilder:categories:foo,bar
+	// +kubebuilder:categories:bar,baz,baz
+	// +kubebuilder:categories:foo
+	type Foo struct{}
+
+	// +kubebuilder:categories:foo
+	type Bar struct{}
+	`,
+			exp: []string{"categories foo,bar", "categories baz", "categories foo"},
+		},
+		{
+			content: `
+	// +kubebuilder:nonNamespaced
+	// +kubebuilder:nonNamespaced
+	type Foo struct{}
+	`,
+			exp: []string{"nonNamespaced nonNamespaced"},
+		},
+		{
+			content: `
+	// +kubebuilder:serveroption:port=7890
+	type Foo struct{}
+
+	// +kubebuilder:serveroption:port=7890,host=foo
+	type Bar struct{}
+	`,
+			exp: []string{"serveroption port=7890", "serveroption port=7890,host=foo"},
+		},
+		{
+			content: `
+	// +kubebuilder:serveroption:port=7890
+	type Foo struct{}
+
+	// +kubebuilder:serveroption:port=443
+	type Bar struct{}
+	`,
+			err: `test.go:6:2: conflicting value of port for module serveroption: "443" here and "7890" at test.go:3:2`,
+		},
+		{
+			content: `
+	// +kubebuilder:single:foo
+	type Foo struct{}
+
+	// +kubebuilder:single:bar
+	type Bar struct{}
+	`,
+			err: `test.go:6:2: module single may occur once per run: "+kubebuilder:single:bar" here and "+kubebuilder:single:foo" at test.go:3:2`,
+		},
+	}
+	for _, test := range tests {
+		calls := []string{}
+		module := func(name string, c Cardinality, m Merge) *Module {
+			return &Module{Name: name, Cardinality: c, Merge: m, Do: func(s string) error {
+				calls = append(calls, name+" "+s)
+				return nil
+			}}
+		}
+		ann := Build()
+		ann.Header("kubebuilder")
+		ann.Module(module("resource", OncePerTarget, MergeKeys))
+		ann.Module(module("categories", Repeatable, MergeUnion))
+		ann.Module(module("nonNamespaced", OncePerTarget, MergeNone))
+		ann.Module(module("serveroption", OncePerRun, MergeKeys))
+		ann.Module(module("single", OncePerRun, MergeNone))
+
+		err := ParseAnnotationByFile(token.NewFileSet(), "test.go", "package foo\n"+test.content, ann)
+		if len(test.err) > 0 {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ParseAnnotationByFile should have succeeded, but got error: %v", err)
+		}
+		if !reflect.DeepEqual(calls, test.exp) {
+			t.Errorf("handlers should have been invoked, expected %v and got %v", test.exp, calls)
+		}
+	}
+}
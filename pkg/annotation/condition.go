@@ -0,0 +1,51 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConditionKey is the element making annotation conditional on feature flags enabled for the generation, e.g.
+// "+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get,if=enterprise". Flags are split by semicolon and
+// all of them should hold, "!" negates flag, e.g. "if=enterprise;!beta".
+const ConditionKey = "if"
+
+func (a *defaultAnnotation) Features(flags ...string) {
+	a.features = map[string]bool{}
+	for _, f := range flags {
+		a.features[f] = true
+	}
+}
+
+// Condition returns annotation without condition element, and whether the condition holds for enabled features.
+// Annotation without condition always applies.
+func (a *defaultAnnotation) Condition(text string) (string, bool, error) {
+	i := a.Resolve(text)
+	if i == nil {
+		return text, true, nil
+	}
+	value, ok := i.Value(ConditionKey)
+	if !ok {
+		return text, true, nil
+	}
+	applies := true
+	for _, flag := range strings.Split(value, ";") {
+		negated := strings.HasPrefix(flag, "!")
+		flag = strings.TrimPrefix(flag, "!")
+		if len(flag) == 0 {
+			return "", false, fmt.Errorf("invalid condition %q of annotation %s, expect %s=<flag>[;!<flag>...]", value, text, ConditionKey)
+		}
+		if a.features[flag] == negated {
+			applies = false
+		}
+	}
+
+	elements := []string{}
+	for _, elem := range strings.Split(i.RawElements, ",") {
+		if key, _, err := ParseKV(elem); err != nil || key != ConditionKey {
+			elements = append(elements, elem)
+		}
+	}
+	stripped := strings.TrimSuffix(strings.TrimSuffix(i.Text, i.RawElements)+strings.Join(elements, ","), ":")
+	return stripped, applies, nil
+}
@@ -0,0 +1,74 @@
+package annotation
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	content := `package foo
+
+	// +kubebuilder:define:reader:+kubebuilder:rbac:groups=apps,verbs=get,if=beta
+	// +kubebuilder:rbac:groups=apps,verbs=get,if=enterprise
+	// +kubebuilder:rbac:groups=batch,verbs=get,if=!enterprise
+	// +kubebuilder:rbac:groups=core,verbs=get,if=enterprise;!beta
+	// +kubebuilder:reader
+	// +kubebuilder:reader:if=community
+	// +kubebuilder:subresource:status,if=enterprise
+	type Foo struct{}
+	`
+	tests := []struct {
+		features []string
+		exp      []string
+	}{
+		{
+			features: []string{"enterprise"},
+			exp:      []string{"rbac groups=apps,verbs=get", "rbac groups=core,verbs=get", "subresource status"},
+		},
+		{
+			features: []string{"enterprise", "beta"},
+			exp:      []string{"rbac groups=apps,verbs=get", "rbac groups=apps,verbs=get", "subresource status"},
+		},
+		{
+			features: []string{"community", "beta"},
+			exp:      []string{"rbac groups=batch,verbs=get", "rbac groups=apps,verbs=get", "rbac groups=apps,verbs=get"},
+		},
+	}
+	for _, test := range tests {
+		ann := Build()
+		ann.Header("kubebuilder")
+		done := []string{}
+		for _, name := range []string{"rbac", "subresource"} {
+			name := name
+			ann.Module(&Module{Name: name, Do: func(s string) error {
+				done = append(done, name+" "+s)
+				return nil
+			}})
+		}
+		ann.Features(test.features...)
+		idx := NewIndex()
+		if err := IndexByFile(token.NewFileSet(), "test.go", content, ann, idx); err != nil {
+			t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+		}
+		if !reflect.DeepEqual(done, test.exp) {
+			t.Errorf("expect handled %v with features %v, got %v", test.exp, test.features, done)
+		}
+		indexed := []string{}
+		for _, i := range idx.Instances() {
+			if i.Module != DefineModule {
+				indexed = append(indexed, i.Module+" "+i.RawElements)
+			}
+		}
+		if !reflect.DeepEqual(indexed, test.exp) {
+			t.Errorf("expect indexed %v with features %v, got %v", test.exp, test.features, indexed)
+		}
+	}
+
+	ann := Build()
+	ann.Header("kubebuilder")
+	exp := `test.go:3:2: invalid condition "enterprise;" of annotation +kubebuilder:rbac:verbs=get,if=enterprise;, expect if=<flag>[;!<flag>...]`
+	if err := ann.ParseAt("+kubebuilder:rbac:verbs=get,if=enterprise;", token.Position{Filename: "test.go", Line: 3, Column: 2}); err == nil || err.Error() != exp {
+		t.Errorf("expect error %q, got %v", exp, err)
+	}
+}
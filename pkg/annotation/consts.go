@@ -0,0 +1,186 @@
+package annotation
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// constRefRegex matches reference to Go constant in annotation value, "${Name}" refers to constant of the same package,
+// and "${pkg.Name}" refers to constant of imported package, e.g. "+kubebuilder:webhook:admission:path=${webhookPath}"
+var constRefRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?)\}`)
+
+// HasConstRef returns true if given string contains reference to Go constant
+func HasConstRef(s string) bool {
+	return constRefRegex.MatchString(s)
+}
+
+// ConstResolver resolves references to Go constants in annotation values through the type checker.
+type ConstResolver struct {
+	pkg *types.Package
+	// errs are type errors of the package, which are reported with constants of unknown value
+	errs []error
+	// failed are errors of imports failing, by import path
+	failed map[string]error
+	// aliases are import paths by names they are imported under in files of the package, e.g. corev1
+	aliases map[string]string
+}
+
+// importerFunc adapts function into types.Importer
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// NewConstResolver type-checks given files of single package and returns resolver of its constants.
+// Imported packages are type-checked from source, so no build of the package is required.
+// Type errors of the package are tolerated, since only declarations of constants are needed. They are logged, and
+// reported with references to constants of package failing to import, or of value unknown due to the errors.
+func NewConstResolver(fset *token.FileSet, files []*ast.File) (*ConstResolver, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files to resolve constants")
+	}
+	r := &ConstResolver{failed: map[string]error{}}
+	imp := importer.ForCompiler(fset, "source", nil)
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			pkg, err := imp.Import(path)
+			if err != nil {
+				r.failed[path] = err
+			}
+			return pkg, err
+		}),
+		Error: func(err error) {
+			Log().V(1).Info("type error resolving constants", "package", files[0].Name.Name, "error", err.Error())
+			r.errs = append(r.errs, err)
+		},
+	}
+	r.pkg, _ = conf.Check(files[0].Name.Name, fset, files, nil)
+	r.aliases = importAliases(files)
+	return r, nil
+}
+
+// importAliases returns import paths by aliases of imports in given files. Blank and dot imports have no alias.
+func importAliases(files []*ast.File) map[string]string {
+	aliases := map[string]string{}
+	for _, f := range files {
+		for _, spec := range f.Imports {
+			if spec.Name == nil || spec.Name.Name == "_" || spec.Name.Name == "." {
+				continue
+			}
+			aliases[spec.Name.Name] = strings.Trim(spec.Path.Value, `"`)
+		}
+	}
+	return aliases
+}
+
+// Errors returns type errors of the package
+func (r *ConstResolver) Errors() []error {
+	return r.errs
+}
+
+// ImportConstResolver type-checks package of given import path from source and returns resolver of its constants.
+// Imports of the package are read from its files for their aliases.
+func ImportConstResolver(path string) (*ConstResolver, error) {
+	fset := token.NewFileSet()
+	pkg, err := importer.ForCompiler(fset, "source", nil).Import(path)
+	if err != nil {
+		return nil, err
+	}
+	bp, err := build.Import(path, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	files := []*ast.File{}
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(bp.Dir, name), nil, parser.ImportsOnly)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return &ConstResolver{pkg: pkg, aliases: importAliases(files)}, nil
+}
+
+// Expand replaces every constant reference in s by the value of the constant.
+// String constants are replaced by their unquoted value, other constants by their exact representation.
+func (r *ConstResolver) Expand(s string) (string, error) {
+	var err error
+	result := constRefRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		var v string
+		v, err = r.lookup(constRefRegex.FindStringSubmatch(ref)[1])
+		return v
+	})
+	return result, err
+}
+
+func (r *ConstResolver) lookup(name string) (string, error) {
+	scope, ident := r.pkg.Scope(), name
+	if parts := strings.SplitN(name, ".", 2); len(parts) == 2 {
+		imported := r.imported(parts[0])
+		if imported == nil {
+			return "", fmt.Errorf("invalid constant reference ${%s}: package %s is not imported by package %s", name, parts[0], r.pkg.Name())
+		}
+		if err, ok := r.failed[imported.Path()]; ok {
+			return "", fmt.Errorf("invalid constant reference ${%s}: package %s failed to import: %v", name, parts[0], err)
+		}
+		scope, ident = imported.Scope(), parts[1]
+	}
+	obj := scope.Lookup(ident)
+	if obj == nil {
+		return "", fmt.Errorf("invalid constant reference ${%s}: %s is not declared", name, name)
+	}
+	c, ok := obj.(*types.Const)
+	if !ok {
+		return "", fmt.Errorf("invalid constant reference ${%s}: %s is not a constant", name, obj)
+	}
+	switch c.Val().Kind() {
+	case constant.Unknown:
+		if len(r.errs) == 0 {
+			return "", fmt.Errorf("invalid constant reference ${%s}: value of %s is unknown", name, name)
+		}
+		more := ""
+		if len(r.errs) > 1 {
+			more = fmt.Sprintf(" and %d more", len(r.errs)-1)
+		}
+		return "", fmt.Errorf("invalid constant reference ${%s}: value of %s is unknown, type error of package %s: %v%s",
+			name, name, r.pkg.Name(), r.errs[0], more)
+	case constant.String:
+		return constant.StringVal(c.Val()), nil
+	}
+	return c.Val().ExactString(), nil
+}
+
+// imported returns package imported by name, which is the alias of import in files of the package, or else the
+// package name or the last element of import path of import without alias
+func (r *ConstResolver) imported(name string) *types.Package {
+	aliased := map[string]bool{}
+	for alias, path := range r.aliases {
+		aliased[path] = true
+		if alias == name {
+			for _, p := range r.pkg.Imports() {
+				if p.Path() == path {
+					return p
+				}
+			}
+		}
+	}
+	for _, p := range r.pkg.Imports() {
+		if !aliased[p.Path()] && (p.Name() == name || filepath.Base(p.Path()) == name) {
+			return p
+		}
+	}
+	return nil
+}
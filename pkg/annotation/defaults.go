@@ -0,0 +1,46 @@
+package annotation
+
+import "strings"
+
+// Inherit declares how annotations of module on a type combine with annotations of the module in package doc,
+// which are defaults of types of the package, e.g. doc.go
+type Inherit int
+
+const (
+	// InheritReplace drops defaults of the module if the type has annotations of the module,
+	// e.g. +genclient:nonNamespaced or +kubebuilder:resource
+	InheritReplace Inherit = iota
+	// InheritAppend keeps defaults of the module followed by annotations of the type, for list-valued modules,
+	// e.g. +kubebuilder:categories and +kubebuilder:printcolumn
+	InheritAppend
+)
+
+// Inherited returns annotations of package doc inherited by a type with given annotations, in order of package doc.
+// Annotations handled by registered modules are inherited, definitions of macros are not. Defaults are compared
+// with annotations of the type by module path, e.g. "subresource:scale", and combined by Inherit of the module.
+// Annotations of the type whose conditions do not hold for enabled features replace no defaults.
+func Inherited(a Annotation, pkg, own []string) []string {
+	declared := map[string]bool{}
+	for _, c := range own {
+		c, applies, err := a.Condition(c)
+		if err != nil || !applies {
+			continue
+		}
+		if i := a.Resolve(c); i != nil {
+			declared[i.Path()] = true
+		}
+	}
+	inherited := []string{}
+	for _, c := range pkg {
+		i := a.Resolve(c)
+		if i == nil || i.Module == DefineModule {
+			continue
+		}
+		m := ResolveModule(a, i.Path())
+		if m == nil || (declared[i.Path()] && m.Inherit == InheritReplace) {
+			continue
+		}
+		inherited = append(inherited, strings.TrimSpace(c))
+	}
+	return inherited
+}
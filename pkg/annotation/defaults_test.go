@@ -0,0 +1,55 @@
+package annotation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInherited(t *testing.T) {
+	ann := Build()
+	ann.Header("kubebuilder")
+	ann.Header("genclient")
+	nop := func(string) error { return nil }
+	ann.Module(&Module{Name: "categories", Do: nop, Merge: MergeUnion, Inherit: InheritAppend})
+	ann.Module(&Module{Name: "nonNamespaced", Do: nop, Cardinality: OncePerTarget})
+	ann.Module(&Module{Name: "resource", Do: nop, Cardinality: OncePerTarget, Merge: MergeKeys})
+	ann.Module(&Module{Name: "subresource", Do: nop, SubModules: map[string]*Module{
+		"scale": &Module{Name: "scale", Do: nop},
+	}})
+	ann.Features("enterprise")
+
+	pkg := []string{
+		"Package v1 contains API Schema definitions of the ship v1 API group",
+		"+k8s:deepcopy-gen=package,register",
+		"+groupName=ship.example.com",
+		"+kubebuilder:define:reader:+kubebuilder:categories:$(category)",
+		"+kubebuilder:categories:ships",
+		"+genclient:nonNamespaced",
+		"+kubebuilder:resource:shortName=sh",
+		"+kubebuilder:subresource:status",
+	}
+	tests := []struct {
+		own []string
+		exp []string
+	}{
+		{
+			own: []string{"Frigate is the Schema for the frigates API"},
+			exp: []string{"+kubebuilder:categories:ships", "+genclient:nonNamespaced", "+kubebuilder:resource:shortName=sh", "+kubebuilder:subresource:status"},
+		},
+		{
+			// categories append, resource and subresource replace by module path
+			own: []string{"+kubebuilder:categories:navy", "+kubebuilder:resource:path=frigates", "+kubebuilder:subresource:scale:specpath=.spec.replicas"},
+			exp: []string{"+kubebuilder:categories:ships", "+genclient:nonNamespaced", "+kubebuilder:subresource:status"},
+		},
+		{
+			// override applies only if its condition holds
+			own: []string{"+kubebuilder:resource:shortName=fg,if=!enterprise", "+kubebuilder:subresource:status,if=enterprise"},
+			exp: []string{"+kubebuilder:categories:ships", "+genclient:nonNamespaced", "+kubebuilder:resource:shortName=sh"},
+		},
+	}
+	for n, test := range tests {
+		if got := Inherited(ann, pkg, test.own); !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("test %d: expect inherited %v, got %v", n, test.exp, got)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package annotation
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Order returns registered modules in dependency order, i.e. every module follows the modules it requires.
+// Modules of the same depth are sorted by name. It returns error if required module is not registered
+// or dependencies form a cycle.
+func (a *defaultAnnotation) Order() ([]*Module, error) {
+	depths, err := a.depths()
+	if err != nil {
+		return nil, err
+	}
+	modules := a.ListModules()
+	sort.SliceStable(modules, func(i, j int) bool {
+		return depths[modules[i].Name] < depths[modules[j].Name]
+	})
+	return modules, nil
+}
+
+// depths returns the length of the longest dependency chain of each module, 0 for module requiring nothing.
+func (a *defaultAnnotation) depths() (map[string]int, error) {
+	depths := map[string]int{}
+	visiting := []string{}
+	var visit func(m *Module) error
+	visit = func(m *Module) error {
+		if _, ok := depths[m.Name]; ok {
+			return nil
+		}
+		for n, name := range visiting {
+			if name == m.Name {
+				cycle := append(visiting[n:], m.Name)
+				return fmt.Errorf("module dependency cycle: %s", strings.Join(cycle, " -> "))
+			}
+		}
+		visiting = append(visiting, m.Name)
+		depth := 0
+		for _, name := range m.requires() {
+			dep := a.GetModule(name)
+			if dep == nil {
+				return fmt.Errorf("module %s requires module %s, which is not registered", m.Name, name)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+			if depths[name]+1 > depth {
+				depth = depths[name] + 1
+			}
+		}
+		visiting = visiting[:len(visiting)-1]
+		depths[m.Name] = depth
+		return nil
+	}
+	for _, m := range a.ListModules() {
+		if err := visit(m); err != nil {
+			return nil, err
+		}
+	}
+	return depths, nil
+}
+
+// requires returns modules required by the module and its submodules
+func (m *Module) requires() []string {
+	requires := append([]string{}, m.Requires...)
+	for _, sub := range m.SubModules {
+		requires = append(requires, sub.requires()...)
+	}
+	sort.Strings(requires)
+	return requires
+}
+
+// Ordered returns annotation lines stably sorted by dependency order of their modules, so that handlers of
+// required modules are invoked first. Lines of independent modules and non-annotation lines keep their order.
+func Ordered(a Annotation, lines []string) ([]string, error) {
+	rank, err := moduleRank(a)
+	if err != nil {
+		return nil, err
+	}
+	ordered := append([]string{}, lines...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return rank(ordered[i]) < rank(ordered[j])
+	})
+	return ordered, nil
+}
+
+// moduleRank returns function ranking annotation line by dependency order of its module
+func moduleRank(a Annotation) (func(string) int, error) {
+	modules, err := a.Order()
+	if err != nil {
+		return nil, err
+	}
+	depths := map[string]int{}
+	for _, m := range modules {
+		for _, name := range m.requires() {
+			if depths[name]+1 > depths[m.Name] {
+				depths[m.Name] = depths[name] + 1
+			}
+		}
+	}
+	// use of macro is ranked by the deepest module it expands into
+	return func(line string) int {
+		lines, err := a.Expand(line, token.Position{})
+		if err != nil {
+			return 0
+		}
+		rank := 0
+		for _, l := range lines {
+			if i := a.Resolve(l.Text); i != nil && depths[i.Module] > rank {
+				rank = depths[i.Module]
+			}
+		}
+		return rank
+	}, nil
+}
+
+// Result assigns the result (Meta) of module of given path to out, which must be a non-nil pointer to a variable
+// the result is assignable to. It gives typed access to results of required modules, e.g.
+//
+//	var res *resource.Resources
+//	err := annotation.Result(a, "resource", &res)
+func Result(a Annotation, path string, out interface{}) error {
+	m := ResolveModule(a, path)
+	if m == nil {
+		return fmt.Errorf("module %s is not registered", path)
+	}
+	if m.Meta == nil {
+		return fmt.Errorf("module %s has no result", path)
+	}
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("result of module %s should be assigned to non-nil pointer, got %T", path, out)
+	}
+	meta := reflect.ValueOf(m.Meta)
+	if !meta.Type().AssignableTo(v.Elem().Type()) {
+		return fmt.Errorf("result of module %s is %T, not assignable to %s", path, m.Meta, v.Elem().Type())
+	}
+	v.Elem().Set(meta)
+	return nil
+}
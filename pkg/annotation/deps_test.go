@@ -0,0 +1,117 @@
+package annotation
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		requires map[string][]string
+		exp      []string
+		err      string
+	}{
+		{
+			requires: map[string][]string{"webhook": {"resource"}, "resource": nil, "categories": nil},
+			exp:      []string{"categories", "resource", "webhook"},
+		},
+		{
+			requires: map[string][]string{"a": {"c"}, "b": nil, "c": {"b"}},
+			exp:      []string{"b", "c", "a"},
+		},
+		{
+			requires: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			err:      "module dependency cycle: a -> b -> c -> a",
+		},
+		{
+			requires: map[string][]string{"webhook": {"resource"}},
+			err:      "module webhook requires module resource, which is not registered",
+		},
+	}
+	for _, test := range tests {
+		ann := Build()
+		for name, requires := range test.requires {
+			ann.Module(&Module{Name: name, Requires: requires, Do: func(string) error { return nil }})
+		}
+		modules, err := ann.Order()
+		if len(test.err) > 0 {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Order should have succeeded, but got error: %v", err)
+		}
+		names := []string{}
+		for _, m := range modules {
+			names = append(names, m.Name)
+		}
+		if !reflect.DeepEqual(names, test.exp) {
+			t.Errorf("modules should have been ordered, expected %v and got %v", test.exp, names)
+		}
+	}
+}
+
+func TestRequires(t *testing.T) {
+	content := `package foo
+
+	// +kubebuilder:webhook:foos
+	// +kubebuilder:categories:foo
+	// +kubebuilder:resource:foos
+	type Foo struct{}
+	`
+	calls := []string{}
+	resources := []string{}
+	ann := Build()
+	ann.Header("kubebuilder")
+	ann.Module(&Module{
+		Name: "resource",
+		Meta: &resources,
+		Do: func(s string) error {
+			calls = append(calls, "resource")
+			resources = append(resources, s)
+			return nil
+		},
+	})
+	ann.Module(&Module{
+		Name: "categories",
+		Do: func(string) error {
+			calls = append(calls, "categories")
+			return nil
+		},
+	})
+	ann.Module(&Module{
+		Name:     "webhook",
+		Requires: []string{"resource"},
+		Do: func(s string) error {
+			calls = append(calls, "webhook")
+			var declared *[]string
+			if err := Result(ann, "resource", &declared); err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(*declared, []string{s}) {
+				t.Errorf("resource should have been declared before webhook, got %v", *declared)
+			}
+			return nil
+		},
+	})
+
+	idx := NewIndex()
+	if err := IndexByFile(token.NewFileSet(), "test.go", content, ann, idx); err != nil {
+		t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+	}
+	exp := []string{"categories", "resource", "webhook"}
+	if !reflect.DeepEqual(calls, exp) {
+		t.Errorf("handlers should have been invoked in dependency order, expected %v and got %v", exp, calls)
+	}
+	if idx.Instances()[0].Module != "webhook" {
+		t.Errorf("annotations should have been indexed in source order, got %v", idx.Instances()[0])
+	}
+
+	var wrong *int
+	if err := Result(ann, "resource", &wrong); err == nil || err.Error() != "result of module resource is *[]string, not assignable to *int" {
+		t.Errorf("result of mismatched type should have failed, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ghodss/yaml"
+)
+
+// DumpEntry is machine-readable record of single annotation instance
+type DumpEntry struct {
+	File     string    `json:"file"`
+	Line     int       `json:"line"`
+	Target   string    `json:"target,omitempty"`
+	Header   string    `json:"header,omitempty"`
+	Modules  []string  `json:"modules"`
+	Elements []Element `json:"elements,omitempty"`
+	Text     string    `json:"text"`
+}
+
+// NewDumpEntry returns dump record of given annotation instance
+func NewDumpEntry(i *Instance) DumpEntry {
+	return DumpEntry{
+		File:     i.Position.Filename,
+		Line:     i.Position.Line,
+		Target:   i.Target,
+		Header:   i.Header,
+		Modules:  append([]string{i.Module}, i.SubModules...),
+		Elements: i.Elements,
+		Text:     i.Text,
+	}
+}
+
+// Dump writes annotation instances to w in given format, which is either "json" or "yaml".
+func Dump(w io.Writer, instances []*Instance, format string) error {
+	entries := []DumpEntry{}
+	for _, i := range instances {
+		entries = append(entries, NewDumpEntry(i))
+	}
+
+	var b []byte
+	var err error
+	switch format {
+	case "json":
+		b, err = json.MarshalIndent(entries, "", "  ")
+		b = append(b, '\n')
+	case "yaml":
+		b, err = yaml.Marshal(entries)
+	default:
+		return fmt.Errorf("unknown dump format %q, expect json or yaml", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,135 @@
+package annotation
+
+import (
+	"bytes"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	i := &Instance{
+		Text:       "+kubebuilder:webhook:admission:groups=apps,type=mutating",
+		Header:     "kubebuilder",
+		Module:     "webhook",
+		SubModules: []string{"admission"},
+		Elements:   []Element{{Key: "groups", Value: "apps"}, {Key: "type", Value: "mutating"}},
+		Target:     "Foo",
+		Position:   token.Position{Filename: "foo.go", Line: 3, Column: 1},
+	}
+	exp := DumpEntry{
+		File:     "foo.go",
+		Line:     3,
+		Target:   "Foo",
+		Header:   "kubebuilder",
+		Modules:  []string{"webhook", "admission"},
+		Elements: []Element{{Key: "groups", Value: "apps"}, {Key: "type", Value: "mutating"}},
+		Text:     "+kubebuilder:webhook:admission:groups=apps,type=mutating",
+	}
+	if e := NewDumpEntry(i); !reflect.DeepEqual(e, exp) {
+		t.Errorf("expect entry %+v, got %+v", exp, e)
+	}
+
+	categories := &Instance{Text: "+categories:foo", Module: "categories", Elements: []Element{{Key: "foo"}}}
+	tests := []struct {
+		format string
+		exp    string
+		err    string
+	}{
+		{
+			format: "json",
+			exp: `[
+  {
+    "file": "foo.go",
+    "line": 3,
+    "target": "Foo",
+    "header": "kubebuilder",
+    "modules": [
+      "webhook",
+      "admission"
+    ],
+    "elements": [
+      {
+        "key": "groups",
+        "value": "apps"
+      },
+      {
+        "key": "type",
+        "value": "mutating"
+      }
+    ],
+    "text": "+kubebuilder:webhook:admission:groups=apps,type=mutating"
+  },
+  {
+    "file": "",
+    "line": 0,
+    "modules": [
+      "categories"
+    ],
+    "elements": [
+      {
+        "key": "foo"
+      }
+    ],
+    "text": "+categories:foo"
+  }
+]
+`,
+		},
+		{
+			format: "yaml",
+			exp: `- elements:
+  - key: groups
+    value: apps
+  - key: type
+    value: mutating
+  file: foo.go
+  header: kubebuilder
+  line: 3
+  modules:
+  - webhook
+  - admission
+  target: Foo
+  text: +kubebuilder:webhook:admission:groups=apps,type=mutating
+- elements:
+  - key: foo
+  file: ""
+  line: 0
+  modules:
+  - categories
+  text: +categories:foo
+`,
+		},
+		{
+			format: "xml",
+			err:    `unknown dump format "xml", expect json or yaml`,
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := Dump(&buf, []*Instance{i, categories}, test.format)
+		if len(test.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expect error of format %s containing %q, got %v", test.format, test.err, err)
+			}
+			if buf.Len() > 0 {
+				t.Errorf("expect nothing written on error, got %q", buf.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error of format %s: %v", test.format, err)
+			continue
+		}
+		if buf.String() != test.exp {
+			t.Errorf("expect %s dump\n%s\ngot\n%s", test.format, test.exp, buf.String())
+		}
+	}
+
+	// no instances are dumped as empty list rather than null
+	var buf bytes.Buffer
+	if err := Dump(&buf, nil, "json"); err != nil || buf.String() != "[]\n" {
+		t.Errorf("expect empty json list, got %q, %v", buf.String(), err)
+	}
+}
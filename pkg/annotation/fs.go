@@ -0,0 +1,32 @@
+package annotation
+
+import (
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	fs   afero.Fs = afero.NewOsFs()
+	fsMu sync.RWMutex
+)
+
+// SetFs sets filesystem which Go files, overlays and project files are read from, and generators write manifests to.
+// The OS filesystem is used by default. E.g. afero.NewMemMapFs() generates fully in memory, and
+// afero.NewCopyOnWriteFs layers unsaved editor buffers over the OS filesystem.
+// Packages imported by constant references are type-checked from the OS filesystem, as the Go toolchain finds them.
+func SetFs(f afero.Fs) {
+	fsMu.Lock()
+	defer fsMu.Unlock()
+	if f == nil {
+		f = afero.NewOsFs()
+	}
+	fs = f
+}
+
+// Fs returns filesystem set by SetFs
+func Fs() afero.Fs {
+	fsMu.RLock()
+	defer fsMu.RUnlock()
+	return fs
+}
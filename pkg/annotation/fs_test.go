@@ -0,0 +1,60 @@
+package annotation
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestFs(t *testing.T) {
+	mem := afero.NewMemMapFs()
+	files := map[string]string{
+		"/src/pkg/foo/foo.go": `package foo
+
+// +kubebuilder:webhook:admission:path=${Path}
+type Foo struct{}
+`,
+		"/src/pkg/foo/consts.go":    "package foo\n\nconst Path = \"/validate-foo\"\n",
+		"/src/pkg/foo/overlay.yaml": "foo.Foo:\n- +kubebuilder:webhook:admission:path=/mutate-foo\n",
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(mem, path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetFs(mem)
+	defer SetFs(nil)
+
+	o, err := LoadOverlay("/src/pkg/foo/overlay.yaml")
+	if err != nil {
+		t.Fatalf("LoadOverlay should have read file of filesystem, but got error: %v", err)
+	}
+	ann := Build()
+	ann.Header("kubebuilder")
+	ann.Overlay(o)
+	done := []string{}
+	ann.Module(&Module{Name: "webhook", SubModules: map[string]*Module{
+		"admission": &Module{Name: "admission", Do: func(s string) error {
+			done = append(done, s)
+			return nil
+		}},
+	}})
+	idx, err := IndexByDir("/src/pkg", ann)
+	if err != nil {
+		t.Fatalf("IndexByDir should have read files of filesystem, but got error: %v", err)
+	}
+	exp := []string{"path=/validate-foo", "path=/mutate-foo"}
+	if !reflect.DeepEqual(done, exp) {
+		t.Errorf("expect handled %v, got %v", exp, done)
+	}
+	positions := []string{}
+	for _, i := range idx.Instances() {
+		positions = append(positions, fmt.Sprint(i.Position))
+	}
+	exp = []string{"/src/pkg/foo/foo.go:3:1", "/src/pkg/foo/overlay.yaml:2:3"}
+	if !reflect.DeepEqual(positions, exp) {
+		t.Errorf("expect annotations at %v, got %v", exp, positions)
+	}
+}
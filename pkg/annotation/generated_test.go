@@ -0,0 +1,64 @@
+package annotation
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestGeneratedHeader(t *testing.T) {
+	content := []byte("kind: ClusterRole\n")
+	generated := WithGeneratedHeader(WithGeneratedHeader(content, "sha256:old"), "sha256:new")
+	exp := "# Code generated by go-annotation devel. DO NOT EDIT.\n# go-annotation:inputs sha256:new\nkind: ClusterRole\n"
+	if string(generated) != exp {
+		t.Errorf("expect header replaced as %q, got %q", exp, generated)
+	}
+	if version, inputs, ok := ReadGeneratedHeader(generated); !ok || version != "devel" || inputs != "sha256:new" {
+		t.Errorf("expect header of version devel and inputs sha256:new, got %q %q %v", version, inputs, ok)
+	}
+	if _, _, ok := ReadGeneratedHeader(content); ok {
+		t.Errorf("expect no header of %q", content)
+	}
+	if stripped := StripGeneratedHeader(generated); string(stripped) != string(content) {
+		t.Errorf("expect header stripped as %q, got %q", content, stripped)
+	}
+}
+
+func TestInputsHash(t *testing.T) {
+	mem := afero.NewMemMapFs()
+	SetFs(mem)
+	defer SetFs(nil)
+	write := func(content string) {
+		if err := afero.WriteFile(mem, "/src/foo/foo.go", []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ann := Build()
+	ann.Header("kubebuilder")
+	nop := func(string) error { return nil }
+	ann.Module(&Module{Name: "rbac", Do: nop})
+	ann.Module(&Module{Name: "resource", Do: nop})
+	hash := func(options ...string) string {
+		h, err := InputsHash("/src", ann, []string{"rbac"}, options...)
+		if err != nil {
+			t.Fatalf("InputsHash should have succeeded, but got error: %v", err)
+		}
+		return h
+	}
+
+	write("package foo\n\n// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get\ntype Foo struct{}\n")
+	h := hash()
+	write("package foo\n\n// Foo is foo\n// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get\n// +kubebuilder:resource:path=foos\ntype Foo struct{}\n")
+	if hash() != h {
+		t.Errorf("expect hash unchanged by comments and annotations of other modules")
+	}
+	if hash("name=manager") == h {
+		t.Errorf("expect hash changed by options")
+	}
+	write("package foo\n\n// +kubebuilder:reader:group=apps\ntype Foo struct{}\n")
+	h = hash()
+	ann.Define(&Macro{Name: "reader", Lines: []Line{{Text: "+kubebuilder:rbac:groups=$(group),resources=deployments,verbs=get"}}})
+	if hash() == h {
+		t.Errorf("expect hash changed by macro registered for use in source")
+	}
+}
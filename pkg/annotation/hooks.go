@@ -0,0 +1,113 @@
+package annotation
+
+import (
+	"sort"
+)
+
+// Target is the declaration annotations are attached to, which is passed to lifecycle hooks of modules
+type Target struct {
+	// Package is the package of declaration, e.g. import path or directory
+	Package string
+	// Name is the name of declaration, e.g. "Foo" for type, "Foo.Bar" for field or method
+	Name string
+	// Object is the declaration object provided by the caller, e.g. *types.Type of gengo. It may be nil
+	Object interface{}
+}
+
+// Hooks are lifecycle hooks of module, which are invoked by registry in the order of
+// OnStartRun, (OnEnterPackage, (OnEnterType, Do..., OnLeaveType)...)..., OnFinish.
+// On each event, hooks of required modules are invoked first, see Module.Requires.
+// Module keeping state per declaration should reset it in OnEnterType instead of being re-registered.
+// Nil hooks are skipped.
+type Hooks struct {
+	// OnStartRun is invoked once before any annotation of the run is parsed
+	OnStartRun func() error
+	// OnEnterPackage is invoked before annotations of given package are parsed
+	OnEnterPackage func(pkg string) error
+	// OnEnterType is invoked before annotations of given declaration are parsed
+	OnEnterType func(t Target) error
+	// OnLeaveType is invoked after all annotations of given declaration are parsed
+	OnLeaveType func(t Target) error
+	// OnFinish is invoked once after all annotations of the run are parsed
+	OnFinish func() error
+}
+
+func (a *defaultAnnotation) StartRun() error {
+	a.occurrences.startRun()
+	a.runMacros = map[string]*Macro{}
+	return a.walkHooks(func(h Hooks) error {
+		if h.OnStartRun == nil {
+			return nil
+		}
+		return h.OnStartRun()
+	})
+}
+
+func (a *defaultAnnotation) EnterPackage(pkg string) error {
+	return a.walkHooks(func(h Hooks) error {
+		if h.OnEnterPackage == nil {
+			return nil
+		}
+		return h.OnEnterPackage(pkg)
+	})
+}
+
+func (a *defaultAnnotation) EnterType(t Target) error {
+	a.occurrences.enterGroup(t)
+	return a.walkHooks(func(h Hooks) error {
+		if h.OnEnterType == nil {
+			return nil
+		}
+		return h.OnEnterType(t)
+	})
+}
+
+func (a *defaultAnnotation) LeaveType(t Target) error {
+	return a.walkHooks(func(h Hooks) error {
+		if h.OnLeaveType == nil {
+			return nil
+		}
+		return h.OnLeaveType(t)
+	})
+}
+
+func (a *defaultAnnotation) Finish() error {
+	return a.walkHooks(func(h Hooks) error {
+		if h.OnFinish == nil {
+			return nil
+		}
+		return h.OnFinish()
+	})
+}
+
+// walkHooks invokes fn on hooks of all registered modules and their submodules, modules are visited
+// in dependency order and parent module before its submodules. It stops at the first error.
+func (a *defaultAnnotation) walkHooks(fn func(Hooks) error) error {
+	modules, err := a.Order()
+	if err != nil {
+		return err
+	}
+	for _, m := range modules {
+		if err := m.walkHooks(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Module) walkHooks(fn func(Hooks) error) error {
+	if err := fn(m.Hooks); err != nil {
+		return err
+	}
+	names := []string{}
+	for name := range m.SubModules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := m.SubModules[name].walkHooks(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package annotation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	content := `package foo
+
+	// +kubebuilder:categories:foo
+	type Foo struct {
+		// +kubebuilder:categories:size
+		Size int
+	}
+
+	// +kubebuilder:categories:bar,baz
+	type Bar struct{}
+	`
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	events := []string{}
+	var categories []string
+	ann := Build()
+	ann.Header("kubebuilder")
+	ann.Module(&Module{
+		Name: "categories",
+		Hooks: Hooks{
+			OnStartRun: func() error {
+				events = append(events, "start")
+				return nil
+			},
+			OnEnterPackage: func(pkg string) error {
+				events = append(events, "package "+filepath.Base(pkg))
+				return nil
+			},
+			OnEnterType: func(target Target) error {
+				categories = nil
+				return nil
+			},
+			OnLeaveType: func(target Target) error {
+				events = append(events, target.Name+" "+strings.Join(categories, ";"))
+				return nil
+			},
+			OnFinish: func() error {
+				events = append(events, "finish")
+				return nil
+			},
+		},
+		Do: func(s string) error {
+			categories = append(categories, s)
+			return nil
+		},
+	})
+
+	if err := ParseAnnotationByDir(dir, ann); err != nil {
+		t.Fatalf("ParseAnnotationByDir should have succeeded, but got error: %v", err)
+	}
+	exp := []string{"start", "package " + filepath.Base(dir), "Foo foo", "Foo.Size size", "Bar bar,baz", "finish"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Errorf("hooks should have been invoked in order, expected %v and got %v", exp, events)
+	}
+
+	events = []string{}
+	if _, err := ScanByDir(dir, ann); err != nil {
+		t.Fatalf("ScanByDir should have succeeded, but got error: %v", err)
+	}
+	if len(events) > 0 {
+		t.Errorf("hooks should not have been invoked by scanning, got %v", events)
+	}
+}
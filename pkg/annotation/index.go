@@ -0,0 +1,97 @@
+package annotation
+
+// Index holds annotation instances found in one load, and answers queries by module, element and target.
+// Module paths in queries match the module itself and its submodules, e.g. "subresource" matches
+// "+kubebuilder:subresource:status" and "+kubebuilder:subresource:scale:...". Declarations in queries are qualified
+// by package, see Instance.Declaration, so same-named declarations of different packages are apart.
+type Index struct {
+	instances []*Instance
+	byModule  map[string][]*Instance
+	byTarget  map[string][]*Instance
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		byModule: map[string][]*Instance{},
+		byTarget: map[string][]*Instance{},
+	}
+}
+
+// Add adds annotation instance into index
+func (x *Index) Add(i *Instance) {
+	x.instances = append(x.instances, i)
+	x.byModule[i.Module] = append(x.byModule[i.Module], i)
+	x.byTarget[i.Declaration()] = append(x.byTarget[i.Declaration()], i)
+}
+
+// Instances returns all annotation instances in the order of adding
+func (x *Index) Instances() []*Instance {
+	return x.instances
+}
+
+// Module returns annotation instances of given module path, e.g. "webhook:admission"
+func (x *Index) Module(path string) []*Instance {
+	return filter(x.byModule[moduleOf(path)], func(i *Instance) bool {
+		return i.hasPath(path)
+	})
+}
+
+// Target returns annotation instances of given declaration
+func (x *Index) Target(target string) []*Instance {
+	return x.byTarget[target]
+}
+
+// HasTarget returns true if given declaration has any annotation
+func (x *Index) HasTarget(target string) bool {
+	return len(x.byTarget[target]) > 0
+}
+
+// Find returns annotation instances of given module path having element key.
+// Empty value matches any value of the key.
+// e.g. Find("webhook:admission", "path", "/bar") returns where webhook path /bar is declared.
+func (x *Index) Find(path, key, value string) []*Instance {
+	return filter(x.Module(path), func(i *Instance) bool {
+		v, ok := i.Value(key)
+		return ok && (len(value) == 0 || v == value)
+	})
+}
+
+// Targets returns declarations carrying annotation of given module path, without duplication
+func (x *Index) Targets(path string) []string {
+	targets := []string{}
+	seen := map[string]bool{}
+	for _, i := range x.Module(path) {
+		if d := i.Declaration(); !seen[d] {
+			seen[d] = true
+			targets = append(targets, d)
+		}
+	}
+	return targets
+}
+
+// Has returns true if given declaration carries annotation of given module path
+func (x *Index) Has(target, path string) bool {
+	return len(x.Lookup(target, path)) > 0
+}
+
+// Lookup returns annotation instances of given module path on given declaration
+func (x *Index) Lookup(target, path string) []*Instance {
+	return filter(x.byTarget[target], func(i *Instance) bool {
+		return i.hasPath(path)
+	})
+}
+
+func moduleOf(path string) string {
+	return splitTokens(path)[0]
+}
+
+func filter(instances []*Instance, fn func(*Instance) bool) []*Instance {
+	result := []*Instance{}
+	for _, i := range instances {
+		if fn(i) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
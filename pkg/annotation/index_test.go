@@ -0,0 +1,253 @@
+package annotation
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestIndexByFile(t *testing.T) {
+	content := `package foo
+
+	// +kubebuilder:resource:path=foos,shortName=fo
+	// +kubebuilder:subresource:status
+	// +kubebuilder:categories:foo,bar
+	type Foo struct {
+		// +kubebuilder:validation:Maximum=10
+		Size int
+	}
+
+	// +kubebuilder:webhook:admission:groups=apps,resources=deployments,path=/bar
+	func bar() {}
+	`
+	ann := Build()
+	ann.Header("kubebuilder")
+	for _, name := range []string{"resource", "subresource", "categories", "validation"} {
+		ann.Module(&Module{Name: name, Do: func(string) error { return nil }})
+	}
+	ann.Module(&Module{
+		Name: "webhook",
+		SubModules: map[string]*Module{
+			"admission": &Module{Name: "admission", Do: func(string) error { return nil }},
+		},
+	})
+
+	idx := NewIndex()
+	if err := IndexByFile(token.NewFileSet(), "test.go", content, ann, idx); err != nil {
+		t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+	}
+
+	tests := []struct {
+		path, key, value string
+		exp              []string
+	}{
+		{path: "resource", exp: []string{"Foo"}},
+		{path: "subresource", key: "status", exp: []string{"Foo"}},
+		{path: "categories", key: "bar", exp: []string{"Foo"}},
+		{path: "validation", key: "Maximum", value: "10", exp: []string{"Foo.Size"}},
+		{path: "webhook:admission", key: "path", value: "/bar", exp: []string{"bar"}},
+		{path: "webhook:admission", key: "path", value: "/baz", exp: []string{}},
+		{path: "webhook", exp: []string{"bar"}},
+	}
+	for _, test := range tests {
+		var targets []string
+		if len(test.key) == 0 {
+			targets = idx.Targets(test.path)
+		} else {
+			targets = []string{}
+			for _, i := range idx.Find(test.path, test.key, test.value) {
+				targets = append(targets, i.Target)
+			}
+		}
+		if !reflect.DeepEqual(targets, test.exp) {
+			t.Errorf("targets of %s %s=%s should have matched, expected %v and got %v", test.path, test.key, test.value, test.exp, targets)
+		}
+	}
+
+	found := idx.Find("webhook:admission", "path", "/bar")
+	if len(found) != 1 || found[0].Position.Line != 11 {
+		t.Errorf("webhook path /bar should have been declared at line 11, got %+v", found)
+	}
+}
+
+func TestIndexByDir(t *testing.T) {
+	mem := afero.NewMemMapFs()
+	SetFs(mem)
+	defer SetFs(nil)
+	files := map[string]string{
+		"/apis/ship/v1/foo.go": "package v1\n\n// +kubebuilder:resource:path=foos\ntype Foo struct{}\n",
+		"/apis/ship/v2/foo.go": "package v2\n\n// +kubebuilder:resource:path=foes\n// +kubebuilder:subresource:status\ntype Foo struct{}\n",
+	}
+	for name, content := range files {
+		if err := afero.WriteFile(mem, name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ann := Build()
+	ann.Header("kubebuilder")
+	for _, name := range []string{"resource", "subresource"} {
+		ann.Module(&Module{Name: name, Cardinality: OncePerTarget, Do: func(string) error { return nil }})
+	}
+
+	idx, err := IndexByDir("/apis", ann)
+	if err != nil {
+		t.Fatalf("IndexByDir should have succeeded, but got error: %v", err)
+	}
+	// same-named declarations of different packages are apart
+	if exp := []string{"/apis/ship/v1.Foo", "/apis/ship/v2.Foo"}; !reflect.DeepEqual(idx.Targets("resource"), exp) {
+		t.Errorf("expect declarations %v, got %v", exp, idx.Targets("resource"))
+	}
+	if found := idx.Lookup("/apis/ship/v1.Foo", "resource"); len(found) != 1 || found[0].RawElements != "path=foos" || found[0].Target != "Foo" {
+		t.Errorf("expect resource foos of v1.Foo, got %+v", found)
+	}
+	if idx.Has("/apis/ship/v1.Foo", "subresource") || !idx.Has("/apis/ship/v2.Foo", "subresource") {
+		t.Errorf("expect subresource of v2.Foo only")
+	}
+	if idx.HasTarget("Foo") {
+		t.Errorf("expect declarations of known packages qualified")
+	}
+}
+
+func TestConstRef(t *testing.T) {
+	content := `package foo
+
+	import "math"
+
+	const (
+		barPath = "/bar"
+		maxSize = 10
+	)
+
+	var notConst = "foo"
+
+	// +kubebuilder:webhook:admission:path=${barPath},name=${math.MaxInt8}
+	// +kubebuilder:validation:Maximum=${maxSize}
+	func bar() {}
+	`
+	tests := []struct {
+		line string
+		exp  string
+		err  string
+	}{
+		{exp: "+kubebuilder:webhook:admission:path=/bar,name=127"},
+		{exp: "+kubebuilder:validation:Maximum=10"},
+		{line: "// +kubebuilder:validation:Maximum=${minSize}", err: "test.go:15:2: invalid constant reference ${minSize}: minSize is not declared"},
+		{line: "// +kubebuilder:validation:Maximum=${notConst}", err: "test.go:15:2: invalid constant reference ${notConst}: var foo.notConst string is not a constant"},
+		{line: "// +kubebuilder:validation:Maximum=${strings.Foo}", err: "test.go:15:2: invalid constant reference ${strings.Foo}: package strings is not imported by package foo"},
+	}
+	for n, test := range tests {
+		ann := Build()
+		ann.Header("kubebuilder")
+		ann.Module(&Module{Name: "webhook", SubModules: map[string]*Module{
+			"admission": &Module{Name: "admission", Do: func(string) error { return nil }},
+		}})
+		ann.Module(&Module{Name: "validation", Do: func(string) error { return nil }})
+		idx := NewIndex()
+		src := content + test.line + "\n"
+		err := IndexByFile(token.NewFileSet(), "test.go", src, ann, idx)
+		if len(test.err) > 0 {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+		}
+		if text := idx.Instances()[n].Text; text != test.exp {
+			t.Errorf("constant reference should have been expanded, expected %q and got %q", test.exp, text)
+		}
+	}
+}
+
+func TestConstRefAlias(t *testing.T) {
+	content := `package foo
+
+	import (
+		gomath "math"
+		"unicode/utf8"
+	)
+
+	// +kubebuilder:validation:Maximum=${gomath.MaxInt8}
+	// +kubebuilder:validation:Minimum=${utf8.RuneSelf}
+	// %s
+	type Foo struct{}
+	`
+	tests := []struct {
+		line string
+		err  string
+	}{
+		{},
+		{line: "+kubebuilder:validation:MultipleOf=${math.MaxInt8}", err: "test.go:10:2: invalid constant reference ${math.MaxInt8}: package math is not imported by package foo"},
+	}
+	for _, test := range tests {
+		ann := Build()
+		ann.Header("kubebuilder")
+		ann.Module(&Module{Name: "validation", Do: func(string) error { return nil }})
+		idx := NewIndex()
+		err := IndexByFile(token.NewFileSet(), "test.go", fmt.Sprintf(content, test.line), ann, idx)
+		if len(test.err) > 0 {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected error %q, got %v", test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+		}
+		got := []string{}
+		for _, i := range idx.Instances() {
+			got = append(got, i.Text)
+		}
+		if exp := []string{"+kubebuilder:validation:Maximum=127", "+kubebuilder:validation:Minimum=128"}; !reflect.DeepEqual(got, exp) {
+			t.Errorf("expect constants of imports by alias expanded %v, got %v", exp, got)
+		}
+	}
+}
+
+func TestConstRefTypeErrors(t *testing.T) {
+	content := `package foo
+
+import "example.com/missing"
+
+const bad = missing.Size + 1
+
+// +kubebuilder:validation:Maximum=%s
+type Foo struct{}
+`
+	tests := []struct {
+		ref string
+		err string
+	}{
+		{ref: "${bad}", err: "test.go:7:1: invalid constant reference ${bad}: value of bad is unknown, type error of package foo: "},
+		{ref: "${missing.Size}", err: "test.go:7:1: invalid constant reference ${missing.Size}: package missing failed to import: "},
+	}
+	for _, test := range tests {
+		ann := Build()
+		ann.Header("kubebuilder")
+		ann.Module(&Module{Name: "validation", Do: func(string) error { return nil }})
+		err := IndexByFile(token.NewFileSet(), "test.go", fmt.Sprintf(content, test.ref), ann, NewIndex())
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) || !strings.Contains(err.Error(), "example.com/missing") {
+			t.Errorf("expect error %q with import error of package, got %v", test.err, err)
+		}
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.go", fmt.Sprintf(content, ""), parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewConstResolver(fset, []*ast.File{f})
+	if err != nil {
+		t.Fatalf("NewConstResolver should have succeeded, but got error: %v", err)
+	}
+	if len(r.Errors()) == 0 {
+		t.Errorf("expect type errors of package failing to import")
+	}
+}
@@ -0,0 +1,82 @@
+package annotation
+
+import (
+	"go/token"
+	"strings"
+)
+
+// Instance is a single annotation found in source, e.g. "+kubebuilder:subresource:scale:specpath=.spec.replicas".
+// It is resolved against registered headers and modules, see Annotation.Resolve.
+type Instance struct {
+	// Text is the whole annotation line including the leading "+"
+	Text string
+	// Header is the header token of annotation, empty if annotation starts with module directly
+	Header string
+	// Module is the name of the module token
+	Module string
+	// SubModules is the chain of submodule tokens following the module
+	SubModules []string
+	// RawElements is the key-value elements token, which is passed to the handler of the last module
+	RawElements string
+	// Elements is the decoded key-value elements. Element without "=" has Key only, e.g. "foo" in "+kubebuilder:categories:foo,bar"
+	Elements []Element
+	// Target is the declaration annotation belongs to, e.g. "Foo" for type, "Foo.Bar" for field or method. It is empty if unknown
+	Target string
+	// Package is the package directory of the declaration, empty if unknown
+	Package string
+	// Position is the source position of the annotation line. It is zero if unknown
+	Position token.Position
+}
+
+// Element is single key-value element of annotation
+type Element struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// Path returns the module chain of annotation joined by colon, e.g. "webhook:admission"
+func (i *Instance) Path() string {
+	return strings.Join(append([]string{i.Module}, i.SubModules...), ":")
+}
+
+// Declaration returns target qualified by package, e.g. "pkg/apis/ship/v1.Foo", which identifies the declaration in
+// loads of several packages. It is Target if package is unknown or the current directory.
+func (i *Instance) Declaration() string {
+	if len(i.Target) == 0 || len(i.Package) == 0 || i.Package == "." {
+		return i.Target
+	}
+	return i.Package + "." + i.Target
+}
+
+// Value returns value of given element key, and whether the key presents
+func (i *Instance) Value(key string) (string, bool) {
+	for _, e := range i.Elements {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// hasPath returns true if instance path equals given path or is nested under it,
+// e.g. "subresource:scale" is nested under "subresource".
+func (i *Instance) hasPath(path string) bool {
+	p := i.Path()
+	return p == path || strings.HasPrefix(p, path+":")
+}
+
+// parseElements decodes key-value elements token split by comma (2nd level delimiter)
+func parseElements(s string) []Element {
+	if len(s) == 0 {
+		return nil
+	}
+	elements := []Element{}
+	for _, elem := range strings.Split(s, ",") {
+		key, value, err := ParseKV(elem)
+		if err != nil {
+			key, value = elem, ""
+		}
+		elements = append(elements, Element{Key: key, Value: value})
+	}
+	return elements
+}
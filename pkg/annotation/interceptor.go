@@ -0,0 +1,93 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Interceptor wraps invocation of handler of module m on annotation instance i. It invokes next to run the
+// handler, or following interceptors, and sees or replaces the result of the handler.
+// Instance has the declaration and source position set if they are known.
+type Interceptor func(m *Module, i *Instance, next func() error) error
+
+func (a *defaultAnnotation) Intercept(interceptors ...Interceptor) {
+	a.interceptors = append(a.interceptors, interceptors...)
+}
+
+// invoke returns function invoking module handler through interceptors on given instance
+func (a *defaultAnnotation) invoke(i *Instance) doFunc {
+	return func(m *Module, elements string) error {
+		if i == nil {
+			i = &Instance{Module: m.Name, RawElements: elements, Elements: parseElements(elements)}
+		}
+		next := func() error {
+			return m.Do(elements)
+		}
+		for n := len(a.interceptors) - 1; n >= 0; n-- {
+			interceptor, inner := a.interceptors[n], next
+			next = func() error {
+				return interceptor(m, i, inner)
+			}
+		}
+		return next()
+	}
+}
+
+// Recover returns interceptor turning panic of module handler into error with position of the annotation
+func Recover() Interceptor {
+	return func(m *Module, i *Instance, next func() error) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%smodule %s failed on %q: %v", location(i.Position, ": "), i.Path(), i.Text, r)
+			}
+		}()
+		return next()
+	}
+}
+
+// Timing returns interceptor reporting duration of every module handler invocation to record
+func Timing(record func(m *Module, i *Instance, d time.Duration)) Interceptor {
+	return func(m *Module, i *Instance, next func() error) error {
+		start := time.Now()
+		err := next()
+		record(m, i, time.Since(start))
+		return err
+	}
+}
+
+// Trace returns interceptor opening span around every module handler invocation. Function start opens span
+// of given name, e.g. "annotation/webhook:admission", and returns function ending the span with result of handler.
+func Trace(start func(name string, i *Instance) (end func(error))) Interceptor {
+	return func(m *Module, i *Instance, next func() error) error {
+		end := start("annotation/"+i.Path(), i)
+		err := next()
+		end(err)
+		return err
+	}
+}
+
+// AuditEntry is record of single module handler invocation written by Audit
+type AuditEntry struct {
+	DumpEntry
+	// Input is the raw key-value elements token of the annotation
+	Input string `json:"input"`
+	Error string `json:"error,omitempty"`
+}
+
+// Audit returns interceptor writing input and result of every module handler invocation to w as JSON lines
+func Audit(w io.Writer) Interceptor {
+	enc := json.NewEncoder(w)
+	return func(m *Module, i *Instance, next func() error) error {
+		err := next()
+		entry := AuditEntry{DumpEntry: NewDumpEntry(i), Input: i.RawElements}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if encErr := enc.Encode(entry); encErr != nil && err == nil {
+			return encErr
+		}
+		return err
+	}
+}
@@ -0,0 +1,59 @@
+package annotation
+
+import (
+	"bytes"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	content := `package foo
+
+	// +kubebuilder:webhook:admission:path=/foo
+	// +kubebuilder:panic:foo
+	type Foo struct{}
+	`
+	calls := []string{}
+	trace := func(name string) Interceptor {
+		return func(m *Module, i *Instance, next func() error) error {
+			calls = append(calls, name+" before "+i.Path())
+			err := next()
+			calls = append(calls, name+" after "+i.Path())
+			return err
+		}
+	}
+	ann := Build()
+	ann.Header("kubebuilder")
+	ann.Module(&Module{Name: "webhook", SubModules: map[string]*Module{
+		"admission": &Module{Name: "admission", Do: func(s string) error {
+			calls = append(calls, "admission "+s)
+			return nil
+		}},
+	}})
+	ann.Module(&Module{Name: "panic", Do: func(s string) error {
+		var m map[string]string
+		m[s] = s
+		return nil
+	}})
+	audit := &bytes.Buffer{}
+	ann.Intercept(Recover(), trace("outer"))
+	ann.Intercept(trace("inner"), Audit(audit))
+
+	err := ParseAnnotationByFile(token.NewFileSet(), "test.go", content, ann)
+	if err == nil || !strings.HasPrefix(err.Error(), `test.go:4:2: module panic failed on "+kubebuilder:panic:foo": `) {
+		t.Errorf("panic of handler should have been recovered as positioned error, got %v", err)
+	}
+	exp := []string{
+		"outer before webhook:admission", "inner before webhook:admission", "admission path=/foo", "inner after webhook:admission", "outer after webhook:admission",
+		"outer before panic", "inner before panic",
+	}
+	if !reflect.DeepEqual(calls, exp) {
+		t.Errorf("interceptors should have been invoked in order, expected %v and got %v", exp, calls)
+	}
+	expAudit := `{"file":"test.go","line":3,"target":"Foo","header":"kubebuilder","modules":["webhook","admission"],"elements":[{"key":"path","value":"/foo"}],"text":"+kubebuilder:webhook:admission:path=/foo","input":"path=/foo"}` + "\n"
+	if audit.String() != expAudit {
+		t.Errorf("handler invocation should have been audited, expected %s and got %s", expAudit, audit.String())
+	}
+}
@@ -0,0 +1,133 @@
+package annotation
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Logger is leveled logger with key-value fields, e.g. "file", "module" and "type".
+// Its methods follow logr, so loggers of controller-runtime are easily adapted.
+type Logger interface {
+	// Info logs message with key-value fields, if verbosity level of the logger is enabled
+	Info(msg string, keysAndValues ...interface{})
+	// Error logs error with message and key-value fields
+	Error(err error, msg string, keysAndValues ...interface{})
+	// V returns logger of given verbosity level, higher level is more verbose
+	V(level int) Logger
+	// WithValues returns logger adding given key-value fields to every message
+	WithValues(keysAndValues ...interface{}) Logger
+}
+
+var (
+	logger   Logger = nopLogger{}
+	loggerMu sync.RWMutex
+)
+
+// SetLogger sets logger of annotation parsing and generators. Logs are discarded by default.
+func SetLogger(l Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	if l == nil {
+		l = nopLogger{}
+	}
+	logger = l
+}
+
+// Log returns logger set by SetLogger
+func Log() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return logger
+}
+
+// nopLogger discards all logs
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...interface{})         {}
+func (nopLogger) Error(error, string, ...interface{}) {}
+func (l nopLogger) V(int) Logger                      { return l }
+func (l nopLogger) WithValues(...interface{}) Logger  { return l }
+
+// writerLogger writes logs as single lines of key-value fields, e.g. `level=1 msg="parsed file" file=foo.go`
+type writerLogger struct {
+	w         io.Writer
+	mu        *sync.Mutex
+	verbosity int
+	level     int
+	values    []interface{}
+}
+
+// NewLogger returns logger writing to w messages of level up to given verbosity, and all errors
+func NewLogger(w io.Writer, verbosity int) Logger {
+	return &writerLogger{w: w, mu: &sync.Mutex{}, verbosity: verbosity}
+}
+
+func (l *writerLogger) Info(msg string, keysAndValues ...interface{}) {
+	if l.level > l.verbosity {
+		return
+	}
+	l.write(fmt.Sprintf("level=%d", l.level), msg, keysAndValues)
+}
+
+func (l *writerLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.write("level=error", msg, append(keysAndValues, "error", err))
+}
+
+func (l *writerLogger) V(level int) Logger {
+	v := *l
+	v.level += level
+	return &v
+}
+
+func (l *writerLogger) WithValues(keysAndValues ...interface{}) Logger {
+	v := *l
+	v.values = append(append([]interface{}{}, l.values...), keysAndValues...)
+	return &v
+}
+
+func (l *writerLogger) write(level, msg string, keysAndValues []interface{}) {
+	fields := []string{level, "msg=" + quote(msg)}
+	kvs := append(append([]interface{}{}, l.values...), keysAndValues...)
+	for n := 0; n < len(kvs); n += 2 {
+		var v interface{} = "<missing>"
+		if n+1 < len(kvs) {
+			v = kvs[n+1]
+		}
+		fields = append(fields, fmt.Sprintf("%v=%s", kvs[n], quote(fmt.Sprint(v))))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, strings.Join(fields, " "))
+}
+
+// quote quotes value containing spaces or quotes
+func quote(s string) string {
+	if strings.ContainsAny(s, " \t\n\"=") || len(s) == 0 {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// Logging returns interceptor logging every module handler invocation and its error at verbosity level 2
+// with fields of the annotation. Logs are written to the logger set by SetLogger if l is nil.
+func Logging(l Logger) Interceptor {
+	return func(m *Module, i *Instance, next func() error) error {
+		log := l
+		if log == nil {
+			log = Log()
+		}
+		fields := []interface{}{"module", i.Path(), "target", i.Target}
+		if i.Position.IsValid() {
+			fields = append(fields, "file", i.Position.Filename, "line", i.Position.Line)
+		}
+		err := next()
+		if err != nil {
+			log.V(2).Info("annotation handler failed", append(fields, "error", err)...)
+			return err
+		}
+		log.V(2).Info("annotation handled", fields...)
+		return nil
+	}
+}
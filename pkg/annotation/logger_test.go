@@ -0,0 +1,48 @@
+package annotation
+
+import (
+	"bytes"
+	"errors"
+	"go/token"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewLogger(buf, 1).WithValues("module", "resource")
+	l.Info("parsed", "type", "Foo")
+	l.V(1).Info("parsed file", "file", "foo.go")
+	l.V(2).Info("hidden")
+	l.V(3).Error(errors.New("bad value"), "failed", "file", "foo.go")
+
+	exp := `level=0 msg=parsed module=resource type=Foo
+level=1 msg="parsed file" module=resource file=foo.go
+level=error msg=failed module=resource file=foo.go error="bad value"
+`
+	if buf.String() != exp {
+		t.Errorf("logs should have matched, expected\n%s\ngot\n%s", exp, buf.String())
+	}
+}
+
+func TestLogging(t *testing.T) {
+	content := `package foo
+
+	// +kubebuilder:resource:path=foos
+	type Foo struct{}
+	`
+	buf := &bytes.Buffer{}
+	SetLogger(NewLogger(buf, 2))
+	defer SetLogger(nil)
+
+	ann := Build()
+	ann.Header("kubebuilder")
+	ann.Module(&Module{Name: "resource", Do: func(string) error { return nil }})
+	ann.Intercept(Logging(nil))
+	if err := ParseAnnotationByFile(token.NewFileSet(), "test.go", content, ann); err != nil {
+		t.Fatalf("ParseAnnotationByFile should have succeeded, but got error: %v", err)
+	}
+	exp := "level=2 msg=\"annotation handled\" module=resource target=Foo file=test.go line=3\n"
+	if buf.String() != exp {
+		t.Errorf("handled annotation should have been logged, expected %q and got %q", exp, buf.String())
+	}
+}
@@ -0,0 +1,169 @@
+package annotation
+
+import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// DefineModule is the module defining macro by annotation, e.g.
+// "+kubebuilder:define:leader-election:+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=$(verbs)"
+const DefineModule = "define"
+
+// paramRegex matches reference to parameter of macro, e.g. "$(verbs)"
+var paramRegex = regexp.MustCompile(`\$\(([A-Za-z0-9_.-]+)\)`)
+
+// Macro is named bundle of annotations. It is used in place of module under registered header, and expanded into
+// its annotations at parse time, e.g. "+kubebuilder:leader-election:verbs=get;update". Annotations of the macro
+// refer to parameters by "$(name)", whose values are given by key-value elements of the use.
+type Macro struct {
+	// Name of the macro, modules of the same name take precedence over it
+	Name string
+	// Lines are annotations of the macro with positions of their definitions
+	Lines []Line
+}
+
+// Params returns names of parameters referred by annotations of the macro in sorted order
+func (m *Macro) Params() []string {
+	params := map[string]bool{}
+	for _, l := range m.Lines {
+		for _, match := range paramRegex.FindAllStringSubmatch(l.Text, -1) {
+			params[match[1]] = true
+		}
+	}
+	names := []string{}
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// add appends line to the macro, line already defined at the same position is ignored
+func (m *Macro) add(l Line) {
+	for _, defined := range m.Lines {
+		if defined == l {
+			return
+		}
+	}
+	m.Lines = append(m.Lines, l)
+}
+
+func (a *defaultAnnotation) Define(m *Macro) {
+	defined, ok := a.macros[m.Name]
+	if !ok {
+		defined = &Macro{Name: m.Name}
+		a.macros[m.Name] = defined
+	}
+	for _, l := range m.Lines {
+		defined.add(l)
+	}
+}
+
+// Macro returns macro defined in the run by annotation, which shadows macro of the same name registered by Define
+func (a *defaultAnnotation) Macro(name string) *Macro {
+	if m, ok := a.runMacros[name]; ok {
+		return m
+	}
+	return a.macros[name]
+}
+
+func (a *defaultAnnotation) Expand(text string, pos token.Position) ([]Line, error) {
+	return a.expand(Line{Text: strings.TrimSpace(text), Position: pos}, nil)
+}
+
+// expand expands line recursively, stack is names of macros being expanded
+func (a *defaultAnnotation) expand(l Line, stack []string) ([]Line, error) {
+	m, elements := a.macroUse(l.Text)
+	if m == nil {
+		return []Line{l}, nil
+	}
+	for _, name := range stack {
+		if name == m.Name {
+			return nil, fmt.Errorf("%smacro %s expands itself: %s -> %s",
+				location(l.Position, ": "), m.Name, strings.Join(stack, " -> "), m.Name)
+		}
+	}
+
+	values := map[string]string{}
+	params := m.Params()
+	known := sets.NewString(params...)
+	for _, e := range elements {
+		if !known.Has(e.Key) {
+			return nil, fmt.Errorf("%smacro %s has no parameter %s, expect one of %v", location(l.Position, ": "), m.Name, e.Key, params)
+		}
+		values[e.Key] = e.Value
+	}
+	for _, p := range params {
+		if _, ok := values[p]; !ok {
+			return nil, fmt.Errorf("%sparameter %s of macro %s is not given", location(l.Position, ": "), p, m.Name)
+		}
+	}
+
+	lines := []Line{}
+	for _, defined := range m.Lines {
+		text := paramRegex.ReplaceAllStringFunc(defined.Text, func(ref string) string {
+			return values[paramRegex.FindStringSubmatch(ref)[1]]
+		})
+		expanded, err := a.expand(Line{Text: text, Position: defined.Position}, append(stack, m.Name))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, expanded...)
+	}
+	return lines, nil
+}
+
+// macroUse returns macro used by annotation and the parameters given, or nil if it is not use of macro
+func (a *defaultAnnotation) macroUse(text string) (*Macro, []Element) {
+	if !strings.HasPrefix(text, "+") {
+		return nil, nil
+	}
+	tokens := splitTokens(strings.TrimPrefix(text, "+"))
+	if len(tokens) < 2 || !a.Headers.Has(tokens[0]) || a.Modules.Has(tokens[1]) {
+		return nil, nil
+	}
+	m := a.Macro(tokens[1])
+	if m == nil {
+		return nil, nil
+	}
+	return m, parseElements(strings.Join(tokens[2:], ":"))
+}
+
+// define registers macro defined by annotation in the run. It returns false if the annotation is not definition.
+func (a *defaultAnnotation) define(text string, pos token.Position) (bool, error) {
+	tokens := splitTokens(strings.TrimPrefix(text, "+"))
+	if !strings.HasPrefix(text, "+") || len(tokens) < 2 || !a.Headers.Has(tokens[0]) || tokens[1] != DefineModule {
+		return false, nil
+	}
+	if len(tokens) < 4 || len(tokens[2]) == 0 || !strings.HasPrefix(tokens[3], "+") {
+		return true, fmt.Errorf("%sinvalid definition of macro %q, expect +%s:%s:<name>:<annotation>",
+			location(pos, ": "), text, tokens[0], DefineModule)
+	}
+	name := tokens[2]
+	m, ok := a.runMacros[name]
+	if !ok {
+		m = &Macro{Name: name}
+		a.runMacros[name] = m
+	}
+	m.add(Line{Text: strings.Join(tokens[3:], ":"), Position: pos})
+	return true, nil
+}
+
+// parseMacro parses annotations expanded from use of macro at pos, errors are traced to both the use and the definition
+func (a *defaultAnnotation) parseMacro(m *Macro, text string, pos token.Position) error {
+	lines, err := a.Expand(text, pos)
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if err := a.ParseAt(l.Text, l.Position); err != nil {
+			return fmt.Errorf("%sin macro %s defined at %s: %v", location(pos, ": "), m.Name, location(l.Position, ""), err)
+		}
+	}
+	return nil
+}
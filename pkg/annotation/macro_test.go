@@ -0,0 +1,152 @@
+package annotation
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestMacro(t *testing.T) {
+	content := `package foo
+
+	// +kubebuilder:define:reader:+kubebuilder:rbac:groups=$(group),resources=$(resources),verbs=get;list;watch
+	// +kubebuilder:define:reader:+kubebuilder:rbac:groups=$(group),resources=$(resources)/status,verbs=get
+	type Foo struct{}
+
+	// +kubebuilder:leader-election:namespace=system
+	// +kubebuilder:reader:group=apps,resources=deployments
+	type Bar struct{}
+	`
+	ann := Build()
+	ann.Header("kubebuilder")
+	done := []string{}
+	ann.Module(&Module{Name: "rbac", Do: func(s string) error {
+		if strings.Contains(s, "namespace=kube-system") {
+			return fmt.Errorf("namespace kube-system is reserved")
+		}
+		done = append(done, s)
+		return nil
+	}})
+	definition := token.Position{Filename: "go-annotation.yaml", Line: 4, Column: 7}
+	ann.Define(&Macro{Name: "leader-election", Lines: []Line{
+		{Text: "+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;update,namespace=$(namespace)", Position: definition},
+	}})
+
+	idx := NewIndex()
+	if err := IndexByFile(token.NewFileSet(), "test.go", content, ann, idx); err != nil {
+		t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+	}
+	exp := []string{
+		"groups=coordination.k8s.io,resources=leases,verbs=get;update,namespace=system",
+		"groups=apps,resources=deployments,verbs=get;list;watch",
+		"groups=apps,resources=deployments/status,verbs=get",
+	}
+	if !reflect.DeepEqual(done, exp) {
+		t.Errorf("expect handled %v, got %v", exp, done)
+	}
+	indexed := []string{}
+	for _, i := range idx.Module("rbac") {
+		indexed = append(indexed, fmt.Sprintf("%s %s %s", i.Target, i.Position, i.RawElements))
+	}
+	exp = []string{
+		"Bar test.go:7:2 " + exp[0],
+		"Bar test.go:8:2 " + exp[1],
+		"Bar test.go:8:2 " + exp[2],
+	}
+	if !reflect.DeepEqual(indexed, exp) {
+		t.Errorf("expect indexed %v, got %v", exp, indexed)
+	}
+	if params := ann.Macro("reader").Params(); !reflect.DeepEqual(params, []string{"group", "resources"}) {
+		t.Errorf("expect params [group resources], got %v", params)
+	}
+
+	tests := []struct {
+		line string
+		err  string
+	}{
+		{
+			line: "+kubebuilder:leader-election:namespace=kube-system",
+			err:  "use.go:3:2: in macro leader-election defined at go-annotation.yaml:4:7: namespace kube-system is reserved",
+		},
+		{
+			line: "+kubebuilder:leader-election",
+			err:  "use.go:3:2: parameter namespace of macro leader-election is not given",
+		},
+		{
+			line: "+kubebuilder:leader-election:namespace=system,name=foo",
+			err:  "use.go:3:2: macro leader-election has no parameter name, expect one of [namespace]",
+		},
+		{
+			line: "+kubebuilder:loop",
+			err:  "go-annotation.yaml:8:3: macro loop expands itself: loop -> loop",
+		},
+		{
+			line: "+kubebuilder:define:writer:kubebuilder:rbac",
+			err:  `use.go:3:2: invalid definition of macro "+kubebuilder:define:writer:kubebuilder:rbac", expect +kubebuilder:define:<name>:<annotation>`,
+		},
+	}
+	ann.Define(&Macro{Name: "loop", Lines: []Line{
+		{Text: "+kubebuilder:loop", Position: token.Position{Filename: "go-annotation.yaml", Line: 8, Column: 3}},
+	}})
+	use := token.Position{Filename: "use.go", Line: 3, Column: 2}
+	for _, test := range tests {
+		if err := ann.ParseAt(test.line, use); err == nil || err.Error() != test.err {
+			t.Errorf("expect error %q of %s, got %v", test.err, test.line, err)
+		}
+	}
+
+	// macros defined by annotations are scoped to the run
+	if err := ann.StartRun(); err != nil {
+		t.Fatal(err)
+	}
+	if ann.Macro("reader") != nil || ann.Macro("leader-election") == nil {
+		t.Errorf("expect macro reader undefined and leader-election defined in new run")
+	}
+}
+
+func TestMacroUsedBeforeDefinition(t *testing.T) {
+	SetFs(afero.NewMemMapFs())
+	defer SetFs(nil)
+	files := map[string]string{
+		// a.go is walked before b.go defining the macro it uses
+		"/pkg/a.go": "package pkg\n\n// +kubebuilder:reader:resources=foos\ntype Foo struct{}\n",
+		"/pkg/b.go": "package pkg\n\n// +kubebuilder:define:reader:+kubebuilder:rbac:groups=ship,resources=$(resources),verbs=get\ntype Bar struct{}\n",
+	}
+	for name, content := range files {
+		if err := afero.WriteFile(Fs(), name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ann := Build()
+	ann.Header("kubebuilder")
+	done := []string{}
+	ann.Module(&Module{Name: "rbac", Do: func(s string) error {
+		done = append(done, s)
+		return nil
+	}})
+	idx, err := IndexByDir("/pkg", ann)
+	if err != nil {
+		t.Fatalf("IndexByDir should have succeeded, but got error: %v", err)
+	}
+	if exp := []string{"groups=ship,resources=foos,verbs=get"}; !reflect.DeepEqual(done, exp) {
+		t.Errorf("expect handled %v, got %v", exp, done)
+	}
+	if i := idx.Lookup("/pkg.Foo", "rbac"); len(i) != 1 || i[0].Position.String() != "/pkg/a.go:3:1" {
+		t.Errorf("expect rbac of Foo indexed at the use of macro, got %v", i)
+	}
+
+	// uses precede definitions in the same file as well
+	done = []string{}
+	content := "package pkg\n\n// +kubebuilder:reader:resources=bars\ntype Foo struct{}\n\n" +
+		"// +kubebuilder:define:reader:+kubebuilder:rbac:groups=ship,resources=$(resources),verbs=get\ntype Bar struct{}\n"
+	if err := ParseAnnotationByFile(token.NewFileSet(), "test.go", strings.NewReader(content), ann); err != nil {
+		t.Fatalf("ParseAnnotationByFile should have succeeded, but got error: %v", err)
+	}
+	if exp := []string{"groups=ship,resources=bars,verbs=get"}; !reflect.DeepEqual(done, exp) {
+		t.Errorf("expect handled %v, got %v", exp, done)
+	}
+}
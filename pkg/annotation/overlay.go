@@ -0,0 +1,154 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/afero"
+)
+
+// Line is annotation text with its position, e.g. in overlay file or in definition of macro
+type Line struct {
+	Text     string
+	Position token.Position
+}
+
+// Overlay holds annotations of declarations which cannot be edited, e.g. third-party types embedded in CRDs.
+// Annotations are merged with source comments of the declarations as if they were written in place.
+// Overlay file maps fully qualified type or field to list of annotations, e.g.
+//
+//	k8s.io/api/core/v1.PodSpec.Containers:
+//	- +kubebuilder:validation:MinItems=1
+//	k8s.io/api/core/v1.ResourceRequirements:
+//	- "+kubebuilder:printcolumn:name=limits,type=string,JSONPath=.spec.limits"
+type Overlay struct {
+	// lines are annotations by package and declaration, which is named "Type" or "Type.Field"
+	lines map[string]map[string][]Line
+}
+
+// LoadOverlay reads overlay file of given path
+func LoadOverlay(path string) (*Overlay, error) {
+	b, err := afero.ReadFile(Fs(), path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseOverlay(path, b)
+}
+
+// ParseOverlay parses content of overlay file, path is the filename of positions
+func ParseOverlay(path string, content []byte) (*Overlay, error) {
+	j, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid overlay file %s: %v", path, err)
+	}
+	decls := map[string][]string{}
+	if err := json.NewDecoder(bytes.NewReader(j)).Decode(&decls); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid overlay file %s: %v", path, err)
+	}
+
+	keys, items := overlayPositions(path, content)
+	o := &Overlay{lines: map[string]map[string][]Line{}}
+	for decl, texts := range decls {
+		pkg, name, err := splitDeclaration(decl)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", keys[decl], err)
+		}
+		for n, text := range texts {
+			pos := keys[decl]
+			if len(items[decl]) == len(texts) {
+				pos = items[decl][n]
+			}
+			if !strings.HasPrefix(text, "+") {
+				return nil, fmt.Errorf("%s: %q of %s is not an annotation, it should start with \"+\"", pos, text, decl)
+			}
+			if o.lines[pkg] == nil {
+				o.lines[pkg] = map[string][]Line{}
+			}
+			o.lines[pkg][name] = append(o.lines[pkg][name], Line{Text: text, Position: pos})
+		}
+	}
+	return o, nil
+}
+
+// Declarations returns fully qualified declarations of the overlay in sorted order
+func (o *Overlay) Declarations() []string {
+	decls := []string{}
+	for pkg, names := range o.lines {
+		for name := range names {
+			decls = append(decls, pkg+"."+name)
+		}
+	}
+	sort.Strings(decls)
+	return decls
+}
+
+// Lines returns annotations of declaration name of given package. Package is either import path, or directory
+// of the package which is matched by suffix of its absolute path, e.g. "vendor/k8s.io/api/core/v1".
+func (o *Overlay) Lines(pkg, name string) []Line {
+	if names, ok := o.lines[pkg]; ok {
+		return names[name]
+	}
+	dir, err := filepath.Abs(pkg)
+	if err != nil {
+		return nil
+	}
+	dir = filepath.ToSlash(dir)
+	for path, names := range o.lines {
+		if strings.HasSuffix(dir, "/"+path) {
+			return names[name]
+		}
+	}
+	return nil
+}
+
+// splitDeclaration splits fully qualified declaration into import path and name, e.g. "k8s.io/api/core/v1.PodSpec.Containers"
+// is split into "k8s.io/api/core/v1" and "PodSpec.Containers".
+func splitDeclaration(decl string) (string, string, error) {
+	slash := strings.LastIndex(decl, "/")
+	dot := strings.Index(decl[slash+1:], ".")
+	if dot < 0 || slash+1+dot == len(decl)-1 {
+		return "", "", fmt.Errorf("invalid declaration %q, expect <import path>.<Type> or <import path>.<Type>.<Field>", decl)
+	}
+	dot += slash + 1
+	return decl[:dot], decl[dot+1:], nil
+}
+
+// overlayPositions returns positions of top level keys and of their block sequence items in overlay file.
+// Items written in flow sequence are not found, and are positioned at their keys.
+func overlayPositions(path string, content []byte) (map[string]token.Position, map[string][]token.Position) {
+	keys := map[string]token.Position{}
+	items := map[string][]token.Position{}
+	key := ""
+	for n, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		pos := token.Position{Filename: path, Line: n + 1, Column: 1}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			offset := strings.Index(line, "-") + 1
+			offset += len(line[offset:]) - len(strings.TrimLeft(line[offset:], " \t"))
+			if offset < len(line) && (line[offset] == '"' || line[offset] == '\'') {
+				offset++
+			}
+			pos.Column = offset + 1
+			items[key] = append(items[key], pos)
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if colon := strings.Index(line, ":"); colon > 0 {
+			key = strings.Trim(strings.TrimSpace(line[:colon]), `"'`)
+			keys[key] = pos
+		}
+	}
+	return keys, items
+}
@@ -0,0 +1,78 @@
+package annotation
+
+import (
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	overlay := `# annotations of types in example.com/api
+example.com/api.Spec:
+- +kubebuilder:categories:foo
+example.com/api.Spec.Replicas:
+-   "+kubebuilder:validation:Maximum=10"
+example.com/api.Status: [+kubebuilder:categories:bar]
+`
+	o, err := ParseOverlay("overlay.yaml", []byte(overlay))
+	if err != nil {
+		t.Fatalf("ParseOverlay should have succeeded, but got error: %v", err)
+	}
+	exp := []string{"example.com/api.Spec", "example.com/api.Spec.Replicas", "example.com/api.Status"}
+	if decls := o.Declarations(); !reflect.DeepEqual(decls, exp) {
+		t.Errorf("expect declarations %v, got %v", exp, decls)
+	}
+
+	content := `package api
+
+	// +kubebuilder:categories:baz
+	type Spec struct {
+		Replicas int
+	}
+
+	type Status struct{}
+	`
+	ann := Build()
+	ann.Header("kubebuilder")
+	done := []string{}
+	for _, name := range []string{"categories", "validation"} {
+		name := name
+		ann.Module(&Module{Name: name, Do: func(s string) error {
+			done = append(done, name+" "+s)
+			return nil
+		}})
+	}
+	ann.Overlay(o)
+
+	idx := NewIndex()
+	if err := IndexByFile(token.NewFileSet(), "/src/example.com/api/types.go", content, ann, idx); err != nil {
+		t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+	}
+	exp = []string{"categories baz", "categories foo", "validation Maximum=10", "categories bar"}
+	if !reflect.DeepEqual(done, exp) {
+		t.Errorf("expect handled %v, got %v", exp, done)
+	}
+	positions := []string{}
+	for _, i := range idx.Instances() {
+		positions = append(positions, i.Target+" "+i.Position.String())
+	}
+	exp = []string{
+		"Spec /src/example.com/api/types.go:3:2",
+		"Spec overlay.yaml:3:3",
+		"Spec.Replicas overlay.yaml:5:6",
+		"Status overlay.yaml:6:1",
+	}
+	if !reflect.DeepEqual(positions, exp) {
+		t.Errorf("expect positions %v, got %v", exp, positions)
+	}
+
+	for _, test := range []struct{ content, err string }{
+		{content: "example.com/api:\n- +kubebuilder:categories:foo\n", err: `overlay.yaml:1:1: invalid declaration "example.com/api"`},
+		{content: "example.com/api.Spec:\n- kubebuilder:categories:foo\n", err: `overlay.yaml:2:3: "kubebuilder:categories:foo" of example.com/api.Spec is not an annotation`},
+	} {
+		if _, err := ParseOverlay("overlay.yaml", []byte(test.content)); err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("expect error %q, got %v", test.err, err)
+		}
+	}
+}
@@ -5,10 +5,15 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+
+	"github.com/spf13/afero"
 )
 
 var (
@@ -19,50 +24,627 @@ var (
 // GetAnnotation returns singleton of annotaiton
 func GetAnnotation() Annotation {
 	once.Do(func() {
-		ann = Build()
-		ann.Header("kubebuilder")
-		ann.Header("genclient") // Header can be applied to any annotations
+		ann = AddDefaults(Build())
+		// panic of module handler is reported as error with position of the annotation
+		ann.Intercept(Logging(nil), Recover())
 	})
 	return ann
 }
 
+// AddDefaults registers default headers and deprecated spellings of annotations of GetAnnotation into a
+func AddDefaults(a Annotation) Annotation {
+	a.Header("kubebuilder")
+	a.Header("genclient") // Header can be applied to any annotations
+	// annotations without header are kept for compatibility
+	a.Deprecate("+rbac", "+kubebuilder:rbac")
+	a.Deprecate("+resource", "+kubebuilder:resource")
+	a.Deprecate("+printcolumn", "+kubebuilder:printcolumn")
+	return a
+}
+
 // ParseAnnotationByDir parses the Go files under given directory and parses the annotation by
-// invoking the Parse function on each comment group (multi-lines comments).
+// invoking the Parse function on each comment group (multi-lines comments). Files are read from Fs.
+// Lifecycle hooks of modules are invoked for the run, each package (directory) and each declaration, see Hooks.
 func ParseAnnotationByDir(dir string, ann Annotation) error {
-	fset := token.NewFileSet()
+	return parseDir(&visitor{ann: ann, parse: true}, dir)
+}
 
-	err := filepath.Walk(dir,
-		func(path string, info os.FileInfo, err error) error {
-			if !isGoFile(info) {
-				return nil
-			}
-			return ParseAnnotationByFile(fset, path, nil, ann)
-		})
-	return err
+// ParseAnnotationByDirs parses annotations of the Go files under given directories as a single run, e.g. a generator
+// reading declarations of its input directory and the APIs directory. Files under more than one directory are
+// parsed once.
+func ParseAnnotationByDirs(dirs []string, ann Annotation) error {
+	return parseDir(&visitor{ann: ann, parse: true}, dirs...)
 }
 
 // ParseAnnotationByFile parses given filename or content src and parses annotations by
 // invoking the parseFn function on each comment group (multi-lines comments).
+// The file is parsed as a run of single package, and lifecycle hooks are invoked as ParseAnnotationByDir does.
 func ParseAnnotationByFile(fset *token.FileSet, path string, src interface{}, ann Annotation) error {
-	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	return parseFile(fset, path, src, &visitor{ann: ann, parse: true})
+}
+
+// IndexByDir parses annotations of the Go files under given directory as ParseAnnotationByDir does,
+// and returns the index of all annotations found with their declarations and positions.
+func IndexByDir(dir string, ann Annotation) (*Index, error) {
+	idx := NewIndex()
+	return idx, parseDir(&visitor{ann: ann, idx: idx, parse: true}, dir)
+}
+
+// IndexByFile parses annotations of given filename or content src as ParseAnnotationByFile does,
+// and adds all annotations found into given index.
+func IndexByFile(fset *token.FileSet, path string, src interface{}, ann Annotation, idx *Index) error {
+	return parseFile(fset, path, src, &visitor{ann: ann, idx: idx, parse: true})
+}
+
+// ScanByDir resolves annotations of the Go files under given directory by registered headers and modules,
+// and returns the index of all annotations found. Unlike IndexByDir, module handlers and hooks are not invoked.
+func ScanByDir(dir string, ann Annotation) (*Index, error) {
+	idx := NewIndex()
+	return idx, parseDir(&visitor{ann: ann, idx: idx}, dir)
+}
+
+// ScanByFile resolves annotations of given filename or content src into given index without invoking module handlers.
+func ScanByFile(fset *token.FileSet, path string, src interface{}, ann Annotation, idx *Index) error {
+	return parseFile(fset, path, src, &visitor{ann: ann, idx: idx})
+}
+
+// visitor handles annotation lines found in files. Resolved annotations are added into idx if it is not nil,
+// module handlers and lifecycle hooks are invoked if parse is true. If defines is true, only macros defined by
+// annotations are registered, see parseDir.
+type visitor struct {
+	ann     Annotation
+	idx     *Index
+	parse   bool
+	defines bool
+	consts  constResolvers
+}
+
+// index adds annotation line into index with the declaration it belongs to and its position.
+// If annotations are parsed, they are indexed as parsed: use of macro is indexed as annotations it expands into
+// at the position of the use, and annotations whose conditions do not hold are skipped.
+func (v *visitor) index(text, pkg, target string, pos token.Position) {
+	if v.idx == nil {
+		return
+	}
+	texts := []string{text}
+	if v.parse {
+		texts = v.parsed(text, pos)
+	}
+	for _, text := range texts {
+		if i := v.ann.Resolve(text); i != nil {
+			i.Target, i.Package = target, pkg
+			i.Position = pos
+			v.idx.Add(i)
+		}
+	}
+}
+
+// parsed returns annotations parsed for given line, see index
+func (v *visitor) parsed(text string, pos token.Position) []string {
+	if i := v.ann.Resolve(text); i != nil && i.Module == DefineModule {
+		return []string{text}
+	}
+	text, applies, err := v.ann.Condition(text)
+	if err != nil || !applies {
+		return nil
+	}
+	lines, err := v.ann.Expand(text, pos)
+	if err != nil {
+		return nil
+	}
+	texts := []string{}
+	for _, l := range lines {
+		if text, applies, err := v.ann.Condition(l.Text); err == nil && applies {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+// hook invokes given lifecycle event of registry if module handlers are invoked
+func (v *visitor) hook(event func() error) error {
+	if !v.parse {
+		return nil
+	}
+	return event()
+}
+
+// parseDir handles files under given directories as a single run. Macros defined by annotations in any file are
+// registered before annotations are parsed, so uses of macros may precede their definitions in the run.
+func parseDir(v *visitor, dirs ...string) error {
+	fset := token.NewFileSet()
+	v.consts = constResolvers{}
+	if err := v.hook(v.ann.StartRun); err != nil {
+		return err
+	}
+	if v.parse {
+		d := &visitor{ann: v.ann, defines: true, consts: v.consts}
+		if err := d.walk(fset, dirs); err != nil {
+			return err
+		}
+	}
+	if err := v.walk(fset, dirs); err != nil {
+		return err
+	}
+	return v.hook(v.ann.Finish)
+}
+
+// walk handles files under given directories, files under more than one directory are handled once
+func (v *visitor) walk(fset *token.FileSet, dirs []string) error {
+	pkg := ""
+	visited := map[string]bool{}
+	for _, dir := range dirs {
+		err := afero.Walk(Fs(), dir,
+			func(path string, info os.FileInfo, err error) error {
+				goFile := isGoFile(info)
+				if !goFile && !v.sourced(info, path) {
+					return nil
+				}
+				if visited[filepath.Clean(path)] {
+					return nil
+				}
+				visited[filepath.Clean(path)] = true
+				if d := filepath.Dir(path); d != pkg {
+					pkg = d
+					if err := v.hook(func() error { return v.ann.EnterPackage(pkg) }); err != nil {
+						return err
+					}
+				}
+				if !goFile {
+					return v.sourceFile(path, nil)
+				}
+				return v.file(fset, path, nil)
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFile handles single file as a run, macros defined in the file are registered first as parseDir does
+func parseFile(fset *token.FileSet, path string, src interface{}, v *visitor) error {
+	v.consts = constResolvers{}
+	if err := v.hook(v.ann.StartRun); err != nil {
+		return err
+	}
+	if err := v.hook(func() error { return v.ann.EnterPackage(filepath.Dir(path)) }); err != nil {
+		return err
+	}
+	if r, ok := src.(io.Reader); ok {
+		// content is read twice, by definitions and by the run
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		src = content
+	}
+	handle := func(v *visitor) error {
+		if !strings.HasSuffix(path, ".go") && v.matches(path) {
+			return v.sourceFile(path, src)
+		}
+		return v.file(fset, path, src)
+	}
+	if v.parse {
+		if err := handle(&visitor{ann: v.ann, defines: true, consts: v.consts}); err != nil {
+			return err
+		}
+	}
+	if err := handle(v); err != nil {
+		return err
+	}
+	return v.hook(v.ann.Finish)
+}
+
+// matches returns true if any registered source reads non-Go file of given path
+func (v *visitor) matches(path string) bool {
+	for _, s := range v.ann.Sources() {
+		if s.Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourced returns true if file of info is non-Go file read by registered sources, hidden files are ignored
+func (v *visitor) sourced(info os.FileInfo, path string) bool {
+	return !info.IsDir() && !strings.HasPrefix(info.Name(), ".") && v.matches(path)
+}
+
+// readSource returns given content src, or content of the file of path read from Fs if src is nil
+func readSource(path string, src interface{}) ([]byte, error) {
+	switch s := src.(type) {
+	case nil:
+		return afero.ReadFile(Fs(), path)
+	case string:
+		return []byte(s), nil
+	case []byte:
+		return s, nil
+	case io.Reader:
+		return ioutil.ReadAll(s)
+	}
+	return nil, fmt.Errorf("invalid source of %s: %T", path, src)
+}
+
+// parseGoFile parses given content src, or the file of path read from Fs if src is nil
+func parseGoFile(fset *token.FileSet, path string, src interface{}, mode parser.Mode) (*ast.File, error) {
+	if src == nil {
+		b, err := afero.ReadFile(Fs(), path)
+		if err != nil {
+			return nil, err
+		}
+		src = b
+	}
+	return parser.ParseFile(fset, path, src, mode)
+}
+
+// constResolvers caches constant resolvers of packages by directory
+type constResolvers map[string]*ConstResolver
+
+// get returns constant resolver of the package of file f. Given content src is type-checked alone,
+// otherwise the package is type-checked with all Go files in the directory of path.
+func (c constResolvers) get(fset *token.FileSet, path string, src interface{}, f *ast.File) (*ConstResolver, error) {
+	if src != nil {
+		return NewConstResolver(fset, []*ast.File{f})
+	}
+	dir := filepath.Dir(path)
+	if r, ok := c[dir]; ok {
+		return r, nil
+	}
+	infos, err := afero.ReadDir(Fs(), dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []*ast.File{}
+	for _, info := range infos {
+		if !isGoFile(info) {
+			continue
+		}
+		pf, err := parseGoFile(fset, filepath.Join(dir, info.Name()), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if pf.Name.Name == f.Name.Name {
+			files = append(files, pf)
+		}
+	}
+	r, err := NewConstResolver(fset, files)
+	if err != nil {
+		return nil, err
+	}
+	c[dir] = r
+	return r, nil
+}
+
+// file handles annotations of single file. Lines of the same declaration are handled together between
+// OnEnterType and OnLeaveType hooks, in the order the declaration first appears in the file.
+// Annotations of registered overlays follow source comments of their declarations, declarations annotated
+// by overlays only are handled after the others.
+// Lines are passed to module handlers in dependency order of modules, and then indexed in source order,
+// so macros defined by the lines are expanded in the index.
+func (v *visitor) file(fset *token.FileSet, path string, src interface{}) error {
+	content, err := readSource(path, src)
+	if err != nil {
+		return err
+	}
+	f, err := parseGoFile(fset, path, content, parser.ParseComments)
+	if err != nil {
+		Log().Error(err, "failed to parse Go file", "file", path)
+		return err
+	}
+	Log().V(3).Info("parsing annotations", "file", path)
+
+	sourced, err := v.sourceLines(fset, path, content, f)
 	if err != nil {
-		fmt.Printf("error from parse.ParseFile: %v", err)
 		return err
 	}
+	pkg := filepath.Dir(path)
+	consts := func() (*ConstResolver, error) { return v.consts.get(fset, path, src, f) }
+	for _, g := range withSourced(v.overlaid(pkg, f, groupComments(f)), sourced) {
+		lines := []commentLine{}
+		for _, cg := range g.comments {
+			lines = append(lines, commentLines(fset, cg)...)
+		}
+		lines = append(lines, g.sourced...)
+		if err := v.declaration(pkg, g.target, lines, consts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceFile handles annotations of non-Go file read by registered sources. Lines of the same declaration are
+// handled together as file does, constant references are not supported.
+func (v *visitor) sourceFile(path string, src interface{}) error {
+	content, err := readSource(path, src)
+	if err != nil {
+		return err
+	}
+	Log().V(3).Info("parsing annotations", "file", path)
+
+	sourced, err := v.sourceLines(nil, path, content, nil)
+	if err != nil {
+		return err
+	}
+	consts := func() (*ConstResolver, error) {
+		return nil, fmt.Errorf("constant references are supported in Go files only")
+	}
+	for _, g := range withSourced(nil, sourced) {
+		if err := v.declaration(filepath.Dir(path), g.target, g.sourced, consts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceLines returns annotations of file read by registered sources
+func (v *visitor) sourceLines(fset *token.FileSet, path string, content []byte, f *ast.File) ([]SourceLine, error) {
+	lines := []SourceLine{}
+	for _, s := range v.ann.Sources() {
+		if f == nil && !s.Match(path) {
+			continue
+		}
+		sl, err := s.Lines(fset, path, content, f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		lines = append(lines, sl...)
+	}
+	return lines, nil
+}
 
-	// using commentMaps here because it sanitizes the comment text by removing
-	// comment markers, compresses newlines etc.
-	cmap := ast.NewCommentMap(fset, f, f.Comments)
-	for _, commentGroup := range cmap.Comments() {
-		err = ann.Parse(commentGroup.Text())
+// withSourced merges annotations read by sources into groups of their declarations, declarations annotated by
+// sources only are appended in the order they first appear
+func withSourced(groups []*commentGroups, lines []SourceLine) []*commentGroups {
+	byTarget := map[string]*commentGroups{}
+	for _, g := range groups {
+		if len(g.target) > 0 {
+			byTarget[g.target] = g
+		}
+	}
+	for _, l := range lines {
+		g, ok := byTarget[l.Target]
+		if !ok || len(l.Target) == 0 {
+			g = &commentGroups{target: l.Target}
+			groups = append(groups, g)
+			if len(l.Target) > 0 {
+				byTarget[l.Target] = g
+			}
+		}
+		g.sourced = append(g.sourced, commentLine{text: l.Text, pos: l.Position})
+	}
+	return groups
+}
+
+// declaration handles lines of single declaration followed by annotations of overlays, after lines are adapted by
+// registered adapters and constant references are expanded by resolver returned by consts
+func (v *visitor) declaration(pkg, target string, lines []commentLine, consts func() (*ConstResolver, error)) error {
+	if len(target) > 0 {
+		for _, l := range v.ann.OverlayLines(pkg, target) {
+			lines = append(lines, commentLine{text: l.Text, pos: l.Position})
+		}
+	}
+	adapted := make([]commentLine, 0, len(lines))
+	for _, l := range lines {
+		texts, err := v.ann.Adapted(l.text)
 		if err != nil {
-			fmt.Print("error when parsing annotation")
+			return fmt.Errorf("%s: %v", l.pos, err)
+		}
+		for _, text := range texts {
+			adapted = append(adapted, commentLine{text: text, pos: l.pos})
+		}
+	}
+	lines = adapted
+	for n, l := range lines {
+		if HasConstRef(l.text) {
+			r, err := consts()
+			if err != nil {
+				return fmt.Errorf("%s: %v", l.pos, err)
+			}
+			if lines[n].text, err = r.Expand(l.text); err != nil {
+				return fmt.Errorf("%s: %v", l.pos, err)
+			}
+		}
+	}
+	if v.defines {
+		return v.define(lines)
+	}
+	if v.parse {
+		if err := v.parseLines(Target{Package: pkg, Name: target}, append([]commentLine{}, lines...)); err != nil {
+			return err
+		}
+	}
+	for _, l := range lines {
+		v.index(l.text, pkg, target, l.pos)
+	}
+	return nil
+}
+
+// overlaid appends groups of declarations of file f which have no comments but are annotated by overlays
+func (v *visitor) overlaid(pkg string, f *ast.File, groups []*commentGroups) []*commentGroups {
+	seen := map[string]bool{}
+	for _, g := range groups {
+		seen[g.target] = true
+	}
+	declarations(f, func(name string, _ ...*ast.CommentGroup) {
+		if !seen[name] && len(v.ann.OverlayLines(pkg, name)) > 0 {
+			seen[name] = true
+			groups = append(groups, &commentGroups{target: name})
+		}
+	})
+	return groups
+}
+
+// define registers macros defined by given lines, other lines are skipped
+func (v *visitor) define(lines []commentLine) error {
+	for _, l := range lines {
+		if i := v.ann.Resolve(l.text); i != nil && len(i.Header) > 0 && i.Module == DefineModule {
+			if err := v.ann.ParseAt(l.text, l.pos); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseLines invokes module handlers on lines of given declaration in dependency order of modules,
+// wrapped by OnEnterType and OnLeaveType hooks if the declaration is known.
+func (v *visitor) parseLines(t Target, lines []commentLine) error {
+	rank, err := moduleRank(v.ann)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return rank(lines[i].text) < rank(lines[j].text)
+	})
+	enterGroup(v.ann, t)
+	if len(t.Name) > 0 {
+		if err := v.ann.EnterType(t); err != nil {
 			return err
 		}
 	}
+	for _, l := range lines {
+		if err := v.ann.ParseAt(l.text, l.pos); err != nil {
+			Log().Error(err, "failed to parse annotation", "file", l.pos.Filename, "line", l.pos.Line, "type", t.Name)
+			return err
+		}
+	}
+	if len(t.Name) > 0 {
+		return v.ann.LeaveType(t)
+	}
 	return nil
 }
 
+// commentGroups are comment groups of the same declaration, target is empty for comments of no declaration.
+// Annotations of the declaration read by sources follow the comments.
+type commentGroups struct {
+	target   string
+	comments []*ast.CommentGroup
+	sourced  []commentLine
+}
+
+// groupComments groups comments of file by declarations in the order they first appear,
+// each comment not belonging to any declaration is a group by itself.
+func groupComments(f *ast.File) []*commentGroups {
+	targets := commentTargets(f)
+	groups := []*commentGroups{}
+	byTarget := map[string]*commentGroups{}
+	for _, cg := range f.Comments {
+		target := targets[cg]
+		if g, ok := byTarget[target]; ok && len(target) > 0 {
+			g.comments = append(g.comments, cg)
+			continue
+		}
+		g := &commentGroups{target: target, comments: []*ast.CommentGroup{cg}}
+		byTarget[target] = g
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+type commentLine struct {
+	text string
+	pos  token.Position
+}
+
+// commentLines splits comment group into single lines with their positions, comment markers are removed.
+func commentLines(fset *token.FileSet, cg *ast.CommentGroup) []commentLine {
+	lines := []commentLine{}
+	for _, c := range cg.List {
+		pos := fset.Position(c.Slash)
+		text := c.Text
+		switch text[1] {
+		case '/':
+			text = text[2:]
+		case '*':
+			text = text[2 : len(text)-2]
+		}
+		for n, l := range strings.Split(text, "\n") {
+			p := pos
+			p.Line += n
+			if n > 0 {
+				p.Column = 1
+			}
+			lines = append(lines, commentLine{text: strings.TrimSpace(l), pos: p})
+		}
+	}
+	return lines
+}
+
+// commentTargets maps doc comment groups to the name of declarations they belong to, see declarations.
+func commentTargets(f *ast.File) map[*ast.CommentGroup]string {
+	targets := map[*ast.CommentGroup]string{}
+	declarations(f, func(name string, cgs ...*ast.CommentGroup) {
+		for _, cg := range cgs {
+			if cg != nil {
+				targets[cg] = name
+			}
+		}
+	})
+	return targets
+}
+
+// declarations visits declarations of file f in source order with their comment groups, which may be nil.
+// Type and function are named as "Foo", field and method are named as "Foo.Bar", file doc is named by package.
+func declarations(f *ast.File, visit func(name string, cgs ...*ast.CommentGroup)) {
+	visit(f.Name.Name, f.Doc)
+	for _, d := range f.Decls {
+		switch decl := d.(type) {
+		case *ast.FuncDecl:
+			name := decl.Name.Name
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				name = receiverName(decl.Recv.List[0].Type) + "." + name
+			}
+			visit(name, decl.Doc)
+		case *ast.GenDecl:
+			// doc of declaration is doc of its spec if it declares single spec
+			var doc *ast.CommentGroup
+			if len(decl.Specs) == 1 {
+				doc = decl.Doc
+			}
+			for _, s := range decl.Specs {
+				switch spec := s.(type) {
+				case *ast.TypeSpec:
+					visit(spec.Name.Name, doc, spec.Doc, spec.Comment)
+					if st, ok := spec.Type.(*ast.StructType); ok {
+						for _, field := range st.Fields.List {
+							visit(spec.Name.Name+"."+fieldName(field), field.Doc, field.Comment)
+						}
+					}
+				case *ast.ValueSpec:
+					visit(spec.Names[0].Name, doc, spec.Doc, spec.Comment)
+				}
+			}
+		}
+	}
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// fieldName returns name of struct field, embedded field is named by its type
+func fieldName(field *ast.Field) string {
+	if len(field.Names) > 0 {
+		return field.Names[0].Name
+	}
+	switch t := field.Type.(type) {
+	case *ast.StarExpr:
+		return fieldName(&ast.Field{Type: t.X})
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
 // OldGetAnnotation extracts the annotation from comment text.
 // It will return "foo" for comment "+kubebuilder:webhook:foo" .
 func OldGetAnnotation(c, name string) string {
@@ -0,0 +1,148 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Usage records module paths whose handlers are invoked, for reporting unused and dead annotations of a run, e.g.
+//
+//	u := NewUsage()
+//	a.Intercept(u.Intercept())
+//	idx, err := IndexByDir("./pkg", a)
+//	report := u.Report(a, idx)
+type Usage struct {
+	invoked map[string]int
+}
+
+// NewUsage returns usage recording no invocation
+func NewUsage() *Usage {
+	return &Usage{invoked: map[string]int{}}
+}
+
+// Intercept returns interceptor recording module paths of invoked handlers
+func (u *Usage) Intercept() Interceptor {
+	return func(m *Module, i *Instance, next func() error) error {
+		path := m.Name
+		if i != nil {
+			path = i.Path()
+		}
+		u.invoked[path]++
+		return next()
+	}
+}
+
+// Invoked returns number of invocations of handler of given module path
+func (u *Usage) Invoked(path string) int {
+	return u.invoked[path]
+}
+
+// Report is unused and dead annotations of a run
+type Report struct {
+	// UnusedModules are paths of registered modules, none of whose handlers or submodule handlers were invoked
+	UnusedModules []string `json:"unusedModules"`
+	// NoEffect are annotations of registered headers or modules which no handler was invoked for, e.g. annotations
+	// of unregistered or disabled modules skipped in lenient mode, such as "+kubebuilder:informers" read by helpers
+	// instead of module handlers
+	NoEffect []DumpEntry `json:"noEffect"`
+	// Unmatched are annotation-looking comments matching no registered header or module, e.g. "+optional"
+	Unmatched []DumpEntry `json:"unmatched"`
+}
+
+// Report reports registered modules of a and annotations of idx against recorded invocations.
+// Definitions of macros are not reported, and uses of macros are reported by annotations they expand into.
+func (u *Usage) Report(a Annotation, idx *Index) *Report {
+	r := &Report{UnusedModules: []string{}, NoEffect: []DumpEntry{}, Unmatched: []DumpEntry{}}
+	for _, m := range a.ListModules() {
+		r.UnusedModules = append(r.UnusedModules, u.unused(m.Name, m)...)
+	}
+	sort.Strings(r.UnusedModules)
+
+	for _, i := range idx.Instances() {
+		switch {
+		case i.Module == DefineModule:
+		case len(i.Header) == 0 && !a.HasModule(i.Module):
+			r.Unmatched = append(r.Unmatched, NewDumpEntry(i))
+		case u.invoked[i.Path()] == 0:
+			r.NoEffect = append(r.NoEffect, NewDumpEntry(i))
+		}
+	}
+	return r
+}
+
+// unused returns paths of module m and its submodules not invoked, m is not reported if any submodule is invoked
+func (u *Usage) unused(path string, m *Module) []string {
+	if u.used(path) {
+		if len(m.SubModules) == 0 {
+			return nil
+		}
+		paths := []string{}
+		for name, sub := range m.SubModules {
+			paths = append(paths, u.unused(path+":"+name, sub)...)
+		}
+		return paths
+	}
+	return []string{path}
+}
+
+// used returns true if handler of module path or of any of its submodules is invoked
+func (u *Usage) used(path string) bool {
+	for p, n := range u.invoked {
+		if n > 0 && (p == path || strings.HasPrefix(p, path+":")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes report to w in given format, which is one of "text", "json" or "yaml"
+func (r *Report) Write(w io.Writer, format string) error {
+	var b []byte
+	var err error
+	switch format {
+	case "text":
+		b = []byte(r.text())
+	case "json":
+		b, err = json.MarshalIndent(r, "", "  ")
+		b = append(b, '\n')
+	case "yaml":
+		b, err = yaml.Marshal(r)
+	default:
+		return fmt.Errorf("unknown report format %q, expect text, json or yaml", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (r *Report) text() string {
+	var b strings.Builder
+	b.WriteString("registered modules never invoked:\n")
+	for _, path := range r.UnusedModules {
+		fmt.Fprintf(&b, "\t%s\n", path)
+	}
+	for _, section := range []struct {
+		title   string
+		entries []DumpEntry
+	}{
+		{"annotations without effect:", r.NoEffect},
+		{"annotations matching no header or module:", r.Unmatched},
+	} {
+		b.WriteString(section.title + "\n")
+		for _, e := range section.entries {
+			fmt.Fprintf(&b, "\t%s:%d: %s", e.File, e.Line, e.Text)
+			if len(e.Target) > 0 {
+				fmt.Fprintf(&b, " (%s)", e.Target)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package annotation
+
+import (
+	"bytes"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	content := `package foo
+
+	// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get
+	// +kubebuilder:informers:group=apps,version=v1,kind=Deployment
+	// +kubebuilder:controller:group=apps,version=v1,kind=Deployment,resource=deployments
+	// +kubebuilder:define:reader:+kubebuilder:rbac:groups=$(group),resources=$(resources),verbs=get
+	// +kubebuilder:reader:group=core,resources=pods
+	// +optional
+	type Foo struct{}
+	`
+	ann := Build()
+	ann.Header("kubebuilder")
+	ann.Strict(false)
+	nop := func(string) error { return nil }
+	ann.Module(&Module{Name: "rbac", Do: nop})
+	ann.Module(&Module{Name: "webhook", SubModules: map[string]*Module{
+		"admission":    &Module{Name: "admission", Do: nop},
+		"serveroption": &Module{Name: "serveroption", Do: nop},
+	}})
+	ann.Module(&Module{Name: "optional", Do: nop})
+	ann.Disable("optional")
+
+	u := NewUsage()
+	ann.Intercept(u.Intercept())
+	idx := NewIndex()
+	if err := IndexByFile(token.NewFileSet(), "test.go", content, ann, idx); err != nil {
+		t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+	}
+	if n := u.Invoked("rbac"); n != 2 {
+		t.Errorf("expect handler of rbac invoked twice, got %d", n)
+	}
+
+	r := u.Report(ann, idx)
+	exp := []string{"webhook"}
+	if !reflect.DeepEqual(r.UnusedModules, exp) {
+		t.Errorf("expect unused modules %v, got %v", exp, r.UnusedModules)
+	}
+	texts := func(entries []DumpEntry) []string {
+		texts := []string{}
+		for _, e := range entries {
+			texts = append(texts, e.Text)
+		}
+		return texts
+	}
+	exp = []string{
+		"+kubebuilder:informers:group=apps,version=v1,kind=Deployment",
+		"+kubebuilder:controller:group=apps,version=v1,kind=Deployment,resource=deployments",
+	}
+	if got := texts(r.NoEffect); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expect annotations without effect %v, got %v", exp, got)
+	}
+	// disabled modules are unregistered
+	if got := texts(r.Unmatched); !reflect.DeepEqual(got, []string{"+optional"}) {
+		t.Errorf("expect unmatched annotation +optional, got %v", got)
+	}
+
+	// submodules are reported once their parent is invoked
+	if err := ann.Parse("+kubebuilder:webhook:admission:path=/foo"); err != nil {
+		t.Fatal(err)
+	}
+	ann.Module(&Module{Name: "storageversion", Do: nop})
+	idx = NewIndex()
+	if err := IndexByFile(token.NewFileSet(), "test.go", "package foo\n\n// +resource:path=foos\ntype Foo struct{}\n", ann, idx); err != nil {
+		t.Fatal(err)
+	}
+	r = u.Report(ann, idx)
+	exp = []string{"storageversion", "webhook:serveroption"}
+	if !reflect.DeepEqual(r.UnusedModules, exp) {
+		t.Errorf("expect unused modules %v, got %v", exp, r.UnusedModules)
+	}
+	if got := texts(r.Unmatched); !reflect.DeepEqual(got, []string{"+resource:path=foos"}) {
+		t.Errorf("expect unmatched annotation +resource:path=foos, got %v", got)
+	}
+
+	out := &bytes.Buffer{}
+	if err := r.Write(out, "text"); err != nil {
+		t.Fatal(err)
+	}
+	expText := "registered modules never invoked:\n\tstorageversion\n\twebhook:serveroption\n" +
+		"annotations without effect:\n" +
+		"annotations matching no header or module:\n\ttest.go:3: +resource:path=foos (Foo)\n"
+	if out.String() != expText {
+		t.Errorf("expect text report %q, got %q", expText, out.String())
+	}
+	if err := r.Write(out, "xml"); err == nil {
+		t.Errorf("expect error of unknown report format")
+	}
+}
@@ -0,0 +1,196 @@
+package annotation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SchemaVersion is the version of the format of schemas exported by ExportSchema, which changes only if the layout
+// of the schema changes. Changes of registered headers, modules and params change Digest of the schema instead.
+const SchemaVersion = 1
+
+// JSONSchema is JSON Schema (draft-07) document, or subschema of it
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Const                interface{}            `json:"const,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	Definitions          map[string]*JSONSchema `json:"definitions,omitempty"`
+
+	// SchemaVersion is the format version of the exported schema, see SchemaVersion
+	SchemaVersion int `json:"x-schema-version,omitempty"`
+	// Generator is the version of go-annotation exporting the schema
+	Generator string `json:"x-generator,omitempty"`
+	// Digest is hash of the schema without Generator and Digest, which changes with the annotation surface
+	Digest string `json:"x-digest,omitempty"`
+	// Syntax is the syntax of annotation strings decoded into instances of the schema
+	Syntax string `json:"x-syntax,omitempty"`
+	// Annotation is the annotation string of the module path without header, e.g. "+webhook:admission"
+	Annotation string `json:"x-annotation,omitempty"`
+}
+
+// constRefPattern matches constant reference valid in place of any value, see HasConstRef
+const constRefPattern = `\$\{[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?\}`
+
+// ExportSchema exports registered headers, modules, submodules, their params and macros as JSON Schema of decoded
+// annotations, e.g. "+kubebuilder:webhook:admission:path=/foo,type=mutating" is decoded into
+//
+//	{"header": "kubebuilder", "modules": ["webhook", "admission"], "elements": {"path": "/foo", "type": "mutating"}}
+//
+// Every module path handling annotations is a definition named by the path, e.g. "webhook:admission", validating
+// its elements. Elements of modules declaring no params are not checked, as Module.ValidateElements does.
+func ExportSchema(a Annotation) *JSONSchema {
+	s := &JSONSchema{
+		Schema:        "http://json-schema.org/draft-07/schema#",
+		Title:         "go-annotation annotations",
+		Description:   "Annotations decoded from strings of syntax " + syntax,
+		Type:          "object",
+		SchemaVersion: SchemaVersion,
+		Syntax:        syntax,
+		Definitions:   map[string]*JSONSchema{},
+	}
+	header := &JSONSchema{Type: "string", Description: "header of the annotation, omitted if the module is written first"}
+	if headers := a.ListHeaders(); len(headers) > 0 {
+		header.Enum = headers
+	}
+	s.Properties = map[string]*JSONSchema{
+		"header":   header,
+		"modules":  {Type: "array", Description: "module and submodules of the annotation", Items: &JSONSchema{Type: "string"}},
+		"elements": {Type: "object", Description: "key-value elements of the annotation"},
+	}
+	s.Required = []string{"modules"}
+
+	var add func(path []string, m *Module)
+	add = func(path []string, m *Module) {
+		path = append(path, m.Name)
+		if m.Do != nil || len(m.SubModules) == 0 {
+			s.Definitions[strings.Join(path, ":")] = moduleSchema(path, m.Doc, m.Params)
+		}
+		names := []string{}
+		for name := range m.SubModules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(append([]string{}, path...), m.SubModules[name])
+		}
+	}
+	for _, m := range a.ListModules() {
+		add(nil, m)
+	}
+	for _, m := range macros(a) {
+		if a.HasModule(m.Name) {
+			continue
+		}
+		params := []Param{}
+		for _, p := range m.Params() {
+			params = append(params, Param{Name: p, Required: true, Doc: "parameter of macro " + m.Name})
+		}
+		s.Definitions[m.Name] = moduleSchema([]string{m.Name}, "macro expanding into "+macroTexts(m), params)
+	}
+
+	paths := []string{}
+	for path := range s.Definitions {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		s.OneOf = append(s.OneOf, &JSONSchema{
+			Properties: map[string]*JSONSchema{
+				"modules":  {Const: strings.Split(path, ":")},
+				"elements": {Ref: "#/definitions/" + path},
+			},
+		})
+	}
+
+	b, _ := json.Marshal(s)
+	sum := sha256.Sum256(b)
+	s.Digest = "sha256:" + hex.EncodeToString(sum[:])
+	s.Generator = "go-annotation " + Version
+	return s
+}
+
+// syntax is the syntax of annotation strings
+const syntax = "+[<header>:]<module>[:<submodule>...][:<key>=<value>,...]"
+
+// moduleSchema returns schema of elements of module path declaring given params
+func moduleSchema(path []string, doc string, params []Param) *JSONSchema {
+	s := &JSONSchema{Type: "object", Description: doc, Annotation: "+" + strings.Join(path, ":")}
+	if len(params) == 0 {
+		return s
+	}
+	closed := false
+	s.AdditionalProperties = &closed
+	s.Properties = map[string]*JSONSchema{
+		ConditionKey: {Type: "string", Description: "feature flags the annotation is conditional on, split by semicolon, \"!\" negates flag"},
+	}
+	for _, p := range params {
+		s.Properties[p.Name] = &JSONSchema{Type: "string", Description: p.Doc, Pattern: valuesPattern(p.Values)}
+		if p.Required {
+			s.Required = append(s.Required, p.Name)
+		}
+	}
+	return s
+}
+
+// valuesPattern returns pattern of semicolon separated values matching any of given values case insensitively,
+// or constant references. It returns empty pattern if any value is valid.
+func valuesPattern(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	alternatives := []string{}
+	for _, v := range values {
+		var b strings.Builder
+		for _, r := range v {
+			if upper, lower := unicode.ToUpper(r), unicode.ToLower(r); upper != lower {
+				b.WriteString("[" + string(upper) + string(lower) + "]")
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		alternatives = append(alternatives, b.String())
+	}
+	value := "(" + strings.Join(append(alternatives, constRefPattern), "|") + ")"
+	return "^" + value + "(;" + value + ")*$"
+}
+
+// macros returns macros registered into a, sorted by name
+func macros(a Annotation) []*Macro {
+	d, ok := a.(*defaultAnnotation)
+	if !ok {
+		return nil
+	}
+	names := []string{}
+	for name := range d.macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ms := []*Macro{}
+	for _, name := range names {
+		ms = append(ms, d.macros[name])
+	}
+	return ms
+}
+
+func macroTexts(m *Macro) string {
+	texts := []string{}
+	for _, l := range m.Lines {
+		texts = append(texts, l.Text)
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,79 @@
+package annotation
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestExportSchema(t *testing.T) {
+	nop := func(string) error { return nil }
+	build := func() Annotation {
+		ann := Build()
+		ann.Header("kubebuilder")
+		ann.Module(&Module{Name: "rbac", Doc: "RBAC rules", Do: nop, Params: []Param{
+			{Name: "groups", Required: true},
+			{Name: "verbs", Required: true, Values: []string{"get", "list"}},
+		}})
+		ann.Module(&Module{Name: "webhook", SubModules: map[string]*Module{
+			"admission": &Module{Name: "admission", Do: nop},
+		}})
+		ann.Define(&Macro{Name: "reader", Lines: []Line{{Text: "+kubebuilder:rbac:groups=$(group),verbs=get"}}})
+		return ann
+	}
+
+	s := ExportSchema(build())
+	if s.SchemaVersion != SchemaVersion {
+		t.Errorf("expect schema version %d, got %d", SchemaVersion, s.SchemaVersion)
+	}
+	if exp := []string{"kubebuilder"}; !reflect.DeepEqual(s.Properties["header"].Enum, exp) {
+		t.Errorf("expect headers %v, got %v", exp, s.Properties["header"].Enum)
+	}
+	paths := []string{}
+	for _, o := range s.OneOf {
+		paths = append(paths, o.Properties["elements"].Ref)
+	}
+	exp := []string{"#/definitions/rbac", "#/definitions/reader", "#/definitions/webhook:admission"}
+	if !reflect.DeepEqual(paths, exp) {
+		t.Errorf("expect module paths %v, got %v", exp, paths)
+	}
+
+	rbac := s.Definitions["rbac"]
+	if exp := []string{"groups", "verbs"}; !reflect.DeepEqual(rbac.Required, exp) {
+		t.Errorf("expect required params %v, got %v", exp, rbac.Required)
+	}
+	if rbac.AdditionalProperties == nil || *rbac.AdditionalProperties {
+		t.Errorf("expect unknown params of rbac rejected")
+	}
+	if _, ok := rbac.Properties[ConditionKey]; !ok {
+		t.Errorf("expect condition key %q allowed", ConditionKey)
+	}
+	verbs := regexp.MustCompile(rbac.Properties["verbs"].Pattern)
+	for v, valid := range map[string]bool{"get": true, "Get;LIST": true, "${Verb}": true, "watch": false, "get;": false} {
+		if verbs.MatchString(v) != valid {
+			t.Errorf("expect value %q valid %v", v, valid)
+		}
+	}
+	if p := rbac.Properties["groups"].Pattern; len(p) > 0 {
+		t.Errorf("expect any value of groups valid, got pattern %q", p)
+	}
+	if admission := s.Definitions["webhook:admission"]; admission.AdditionalProperties != nil {
+		t.Errorf("expect elements of module without params unchecked")
+	}
+	if exp := []string{"group"}; !reflect.DeepEqual(s.Definitions["reader"].Required, exp) {
+		t.Errorf("expect required params of macro %v, got %v", exp, s.Definitions["reader"].Required)
+	}
+
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatalf("marshal should have succeeded, but got error: %v", err)
+	}
+	if again := ExportSchema(build()); again.Digest != s.Digest {
+		t.Errorf("expect the same digest of the same registry, got %s and %s", s.Digest, again.Digest)
+	}
+	changed := build()
+	changed.Module(&Module{Name: "informers", Do: nop})
+	if again := ExportSchema(changed); again.Digest == s.Digest {
+		t.Errorf("expect digest changed with registered modules")
+	}
+}
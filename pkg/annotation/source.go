@@ -0,0 +1,207 @@
+package annotation
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Source reads annotations from other than Go comments, e.g. struct tags or comments of YAML files. Annotations of
+// registered sources are resolved by the same registry and handled by the same modules as Go comments, following
+// comments of their declarations.
+type Source interface {
+	// Match returns true if the source reads non-Go file of given path, e.g. by extension
+	Match(path string) bool
+	// Lines returns annotations of file of given path and content with declarations they belong to.
+	// Go file is given parsed with comments as f, which is nil for non-Go files.
+	Lines(fset *token.FileSet, path string, content []byte, f *ast.File) ([]SourceLine, error)
+}
+
+// SourceLine is annotation read by Source
+type SourceLine struct {
+	Line
+	// Target is the declaration annotation belongs to, see Instance.Target
+	Target string
+}
+
+func (a *defaultAnnotation) Source(s Source) {
+	a.sources = append(a.sources, s)
+}
+
+func (a *defaultAnnotation) Sources() []Source {
+	return a.sources
+}
+
+// DefaultTagKey is the struct tag key read by TagSource by default
+const DefaultTagKey = "annotation"
+
+// TagSource reads annotations of struct fields from struct tag of Key, DefaultTagKey if empty. Annotations are
+// separated by spaces, e.g.
+//
+//	Replicas int32 `json:"replicas" annotation:"+kubebuilder:validation:Minimum=0 +kubebuilder:validation:Maximum=10"`
+type TagSource struct {
+	Key string
+}
+
+// tagSeparator separates annotations in struct tag
+var tagSeparator = regexp.MustCompile(`\s+\+`)
+
+// Match returns false, struct tags are read from Go files only
+func (s *TagSource) Match(string) bool {
+	return false
+}
+
+// Lines returns annotations of struct tags of fields, positioned in the tags
+func (s *TagSource) Lines(fset *token.FileSet, path string, content []byte, f *ast.File) ([]SourceLine, error) {
+	if f == nil {
+		return nil, nil
+	}
+	lines := []SourceLine{}
+	for _, d := range f.Decls {
+		decl, ok := d.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, field := range st.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				tag, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					continue
+				}
+				for _, text := range s.annotations(tag) {
+					pos := fset.Position(field.Tag.Pos())
+					if offset := strings.Index(field.Tag.Value, text); offset >= 0 {
+						pos.Offset += offset
+						pos.Column += offset
+					}
+					lines = append(lines, SourceLine{Line: Line{Text: text, Position: pos}, Target: ts.Name.Name + "." + fieldName(field)})
+				}
+			}
+		}
+	}
+	return lines, nil
+}
+
+// annotations returns annotations of given struct tag
+func (s *TagSource) annotations(tag string) []string {
+	key := s.Key
+	if len(key) == 0 {
+		key = DefaultTagKey
+	}
+	value, ok := reflect.StructTag(tag).Lookup(key)
+	value = strings.TrimSpace(value)
+	if !ok || len(value) == 0 {
+		return nil
+	}
+	texts := tagSeparator.Split(value, -1)
+	for n := range texts[1:] {
+		texts[n+1] = "+" + texts[n+1]
+	}
+	return texts
+}
+
+// TagLines returns annotations of struct tag read by registered TagSources, e.g. for parsers of fields of types
+// loaded by other means than parsing Go files
+func TagLines(a Annotation, tag string) []string {
+	lines := []string{}
+	for _, s := range a.Sources() {
+		if ts, ok := s.(*TagSource); ok {
+			lines = append(lines, ts.annotations(tag)...)
+		}
+	}
+	return lines
+}
+
+// YAMLSource reads annotations from comments of YAML files, e.g. "# +kubebuilder:printcolumn:...". Comments before
+// the content of a document belong to the document, named by its kind, e.g. "Frigate". Other comments belong to
+// the key on the following line, named by the kind and the path of the key, e.g. "Frigate.spec.replicas".
+type YAMLSource struct{}
+
+// yamlKey matches key of YAML mapping, which may be item of sequence
+var yamlKey = regexp.MustCompile(`^(- )?([^\s:#'"]+):(\s|$)`)
+
+// Match returns true for files of extension .yaml or .yml
+func (YAMLSource) Match(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Lines returns annotations of comment lines of YAML content, positioned at the comment markers
+func (YAMLSource) Lines(fset *token.FileSet, path string, content []byte, f *ast.File) ([]SourceLine, error) {
+	type key struct {
+		indent int
+		name   string
+	}
+	lines := []SourceLine{}
+	// doc is lines of the current document, whose targets are prefixed by kind at the end of the document
+	var doc, pending []SourceLine
+	var keys []key
+	kind, started := "", false
+	flush := func() {
+		for _, l := range append(doc, pending...) {
+			l.Target = strings.TrimPrefix(kind+"."+l.Target, ".")
+			l.Target = strings.TrimSuffix(l.Target, ".")
+			lines = append(lines, l)
+		}
+		doc, pending, keys, kind, started = nil, nil, nil, "", false
+	}
+
+	for n, l := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(l)
+		switch {
+		case trimmed == "---" || strings.HasPrefix(trimmed, "--- "):
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			column := strings.Index(l, "#")
+			pos := token.Position{Filename: path, Line: n + 1, Column: column + 1}
+			text := strings.TrimSpace(strings.TrimPrefix(trimmed, "#"))
+			pending = append(pending, SourceLine{Line: Line{Text: text, Position: pos}})
+		case len(trimmed) > 0:
+			indent := len(l) - len(strings.TrimLeft(l, " "))
+			m := yamlKey.FindStringSubmatch(trimmed)
+			if len(m) > 0 && len(m[1]) > 0 {
+				indent += len(m[1])
+			}
+			for len(keys) > 0 && keys[len(keys)-1].indent >= indent {
+				keys = keys[:len(keys)-1]
+			}
+			target := ""
+			if len(m) > 0 {
+				keys = append(keys, key{indent: indent, name: m[2]})
+				if indent == 0 && m[2] == "kind" {
+					kind = strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "kind:")), `"'`)
+				}
+				names := []string{}
+				for _, k := range keys {
+					names = append(names, k.name)
+				}
+				target = strings.Join(names, ".")
+			}
+			if !started {
+				target = ""
+			}
+			for _, p := range pending {
+				p.Target = target
+				doc = append(doc, p)
+			}
+			pending, started = nil, true
+		}
+	}
+	flush()
+	return lines, nil
+}
@@ -0,0 +1,107 @@
+package annotation
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestSources(t *testing.T) {
+	build := func() (Annotation, *[]string) {
+		ann := Build()
+		ann.Header("kubebuilder")
+		handled := []string{}
+		for _, name := range []string{"resource", "printcolumn", "validation"} {
+			name := name
+			ann.Module(&Module{Name: name, Do: func(s string) error {
+				handled = append(handled, name+" "+s)
+				return nil
+			}})
+		}
+		ann.Source(&TagSource{})
+		ann.Source(YAMLSource{})
+		return ann, &handled
+	}
+	entries := func(idx *Index) []string {
+		got := []string{}
+		for _, i := range idx.Instances() {
+			got = append(got, fmt.Sprintf("%d:%d %s %s", i.Position.Line, i.Position.Column, i.Target, i.Text))
+		}
+		return got
+	}
+
+	goContent := `package foo
+
+// +kubebuilder:resource:path=foos
+type Foo struct {
+	// +kubebuilder:validation:Maximum=10
+	Size int ` + "`" + `json:"size" annotation:"+kubebuilder:validation:Minimum=0 +kubebuilder:validation:MultipleOf=2"` + "`" + `
+	Name string ` + "`" + `annotation:"+kubebuilder:validation:MaxLength=63"` + "`" + `
+	Kind string ` + "`" + `json:"kind"` + "`" + `
+}
+`
+	ann, handled := build()
+	idx := NewIndex()
+	if err := IndexByFile(token.NewFileSet(), "test.go", goContent, ann, idx); err != nil {
+		t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+	}
+	exp := []string{
+		"3:1 Foo +kubebuilder:resource:path=foos",
+		"5:2 Foo.Size +kubebuilder:validation:Maximum=10",
+		"6:36 Foo.Size +kubebuilder:validation:Minimum=0",
+		"6:70 Foo.Size +kubebuilder:validation:MultipleOf=2",
+		"7:27 Foo.Name +kubebuilder:validation:MaxLength=63",
+	}
+	if got := entries(idx); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expect annotations of Go file %v, got %v", exp, got)
+	}
+	if n := len(*handled); n != 5 {
+		t.Errorf("expect 5 annotations handled, got %d: %v", n, *handled)
+	}
+
+	if got, exp := TagLines(ann, `json:"size" annotation:"+kubebuilder:validation:Minimum=0"`), []string{"+kubebuilder:validation:Minimum=0"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expect annotations of tag %v, got %v", exp, got)
+	}
+
+	yamlContent := `# Frigate is a sample
+# +kubebuilder:resource:path=frigates
+apiVersion: ship.example.com/v1
+kind: Frigate
+spec:
+  # +kubebuilder:validation:Minimum=1
+  replicas: 3
+  crew:
+  - name: captain
+    # +kubebuilder:validation:MaxLength=10
+    rank: one
+---
+kind: Destroyer
+# +kubebuilder:printcolumn:name=age,type=date,JSONPath=.metadata.creationTimestamp
+metadata:
+  name: foo
+`
+	ann, handled = build()
+	idx = NewIndex()
+	if err := IndexByFile(token.NewFileSet(), "frigate.yaml", yamlContent, ann, idx); err != nil {
+		t.Fatalf("IndexByFile should have succeeded, but got error: %v", err)
+	}
+	exp = []string{
+		"2:1 Frigate +kubebuilder:resource:path=frigates",
+		"6:3 Frigate.spec.replicas +kubebuilder:validation:Minimum=1",
+		"10:5 Frigate.spec.crew.rank +kubebuilder:validation:MaxLength=10",
+		"14:1 Destroyer.metadata +kubebuilder:printcolumn:name=age,type=date,JSONPath=.metadata.creationTimestamp",
+	}
+	if got := entries(idx); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expect annotations of YAML file %v, got %v", exp, got)
+	}
+	if n := len(*handled); n != 4 {
+		t.Errorf("expect 4 annotations handled, got %d: %v", n, *handled)
+	}
+
+	ann, _ = build()
+	err := IndexByFile(token.NewFileSet(), "frigate.yaml", "# +kubebuilder:validation:Maximum=${Max}\nkind: Frigate\n", ann, NewIndex())
+	if exp := "frigate.yaml:1:1: constant references are supported in Go files only"; err == nil || err.Error() != exp {
+		t.Errorf("expect error %q, got %v", exp, err)
+	}
+}
@@ -2,6 +2,7 @@ package annotation
 
 import (
 	"fmt"
+	"go/token"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -26,12 +27,111 @@ type Annotation interface {
 
 	// Parse takes single comment group and parse registered annotation
 	Parse(string) error
+
+	// ParseAt parses single line of comment at given source position, which is reported by cardinality conflicts
+	// and passed to interceptors
+	ParseAt(string, token.Position) error
+
+	// Intercept appends interceptors wrapping every invocation of module handlers, the first is the outermost
+	Intercept(...Interceptor)
+
+	// Disable disables module of given name, it is not registered and its annotations are ignored
+	Disable(string)
+
+	// Strict sets whether annotation of unregistered module under registered header is an error, it is true by default
+	Strict(bool)
+
+	// Overlay registers overlay, whose annotations are merged with source comments of declarations by parsers
+	Overlay(*Overlay)
+
+	// OverlayLines returns annotations of registered overlays on declaration of given package and name, see Overlay.Lines
+	OverlayLines(pkg, name string) []Line
+
+	// Source registers source adapter, whose annotations are merged with source comments of declarations by parsers,
+	// and whose non-Go files are parsed with Go files, see Source
+	Source(Source)
+
+	// Sources returns registered source adapters
+	Sources() []Source
+
+	// Adapt registers input adapter, which rewrites annotations written for other tools into annotations of
+	// registered modules as they are read by parsers, see Adapter
+	Adapt(Adapter)
+
+	// Adapted returns annotations given line is rewritten into by registered adapters, or the line itself
+	Adapted(string) ([]string, error)
+
+	// Define registers macro, lines of macro of the same name are appended. Macros defined by annotation
+	// "+<header>:define:<name>:<annotation>" are registered on parsing and are scoped to the run, see Macro
+	Define(*Macro)
+
+	// Macro returns macro of given name, or nil if it is not defined
+	Macro(string) *Macro
+
+	// Expand expands use of macro into annotations of the macro with parameters substituted, positioned at their
+	// definitions. Other annotations are returned as they are
+	Expand(string, token.Position) ([]Line, error)
+
+	// Features sets feature flags enabled for the generation, replacing flags set before. Annotations conditional
+	// on flags by ConditionKey element are parsed only if their conditions hold
+	Features(...string)
+
+	// Condition returns annotation without condition element, and whether its condition holds for enabled features
+	Condition(string) (string, bool, error)
+
+	// Resolve takes single line of comment and resolves it into annotation instance by registered headers and modules.
+	// It returns nil if the comment is not an annotation.
+	Resolve(string) *Instance
+
+	// ListHeaders returns registered headers in sorted order
+	ListHeaders() []string
+
+	// ListModules returns registered modules sorted by name
+	ListModules() []*Module
+
+	// Deprecate registers deprecated spelling of annotation prefix and its replacement,
+	// e.g. "+rbac" is replaced by "+kubebuilder:rbac"
+	Deprecate(old, new string)
+
+	// Deprecation returns the preferred spelling of given annotation if it uses deprecated spelling
+	Deprecation(string) (string, bool)
+
+	// Order returns registered modules in dependency order, see Module.Requires.
+	// It returns error if required module is not registered or dependencies form a cycle.
+	Order() ([]*Module, error)
+
+	// StartRun invokes OnStartRun hooks of registered modules in dependency order, see Hooks
+	StartRun() error
+
+	// EnterPackage invokes OnEnterPackage hooks of registered modules with given package
+	EnterPackage(string) error
+
+	// EnterType invokes OnEnterType hooks of registered modules with given declaration
+	EnterType(Target) error
+
+	// LeaveType invokes OnLeaveType hooks of registered modules with given declaration
+	LeaveType(Target) error
+
+	// Finish invokes OnFinish hooks of registered modules
+	Finish() error
 }
 
 type defaultAnnotation struct {
-	Headers   sets.String
-	Modules   sets.String
-	ModuleMap map[string]*Module
+	Headers      sets.String
+	Modules      sets.String
+	ModuleMap    map[string]*Module
+	Deprecations map[string]string
+	occurrences  *occurrences
+	interceptors []Interceptor
+	disabled     sets.String
+	lenient      bool
+	overlays     []*Overlay
+	sources      []Source
+	adapters     []Adapter
+	// macros are registered by Define, runMacros are defined by annotations in current run
+	macros    map[string]*Macro
+	runMacros map[string]*Macro
+	features  map[string]bool
 }
 
 func (a *defaultAnnotation) Header(header string) {
@@ -39,10 +139,35 @@ func (a *defaultAnnotation) Header(header string) {
 }
 
 func (a *defaultAnnotation) Module(m *Module) {
+	if a.disabled.Has(m.Name) {
+		return
+	}
 	a.Modules.Insert(m.Name)
 	a.ModuleMap[m.Name] = m
 }
 
+func (a *defaultAnnotation) Disable(name string) {
+	a.disabled.Insert(name)
+	a.Modules.Delete(name)
+	delete(a.ModuleMap, name)
+}
+
+func (a *defaultAnnotation) Strict(strict bool) {
+	a.lenient = !strict
+}
+
+func (a *defaultAnnotation) Overlay(o *Overlay) {
+	a.overlays = append(a.overlays, o)
+}
+
+func (a *defaultAnnotation) OverlayLines(pkg, name string) []Line {
+	lines := []Line{}
+	for _, o := range a.overlays {
+		lines = append(lines, o.Lines(pkg, name)...)
+	}
+	return lines
+}
+
 func (a *defaultAnnotation) HasModule(name string) bool {
 	return a.Modules.Has(name)
 }
@@ -56,33 +181,134 @@ func (a *defaultAnnotation) GetModule(name string) *Module {
 	return nil
 }
 
+func (a *defaultAnnotation) ListHeaders() []string {
+	return a.Headers.List()
+}
+
+func (a *defaultAnnotation) ListModules() []*Module {
+	modules := []*Module{}
+	for _, name := range a.Modules.List() {
+		modules = append(modules, a.ModuleMap[name])
+	}
+	return modules
+}
+
+func (a *defaultAnnotation) Deprecate(old, new string) {
+	a.Deprecations[old] = new
+}
+
+// Deprecation matches deprecated prefixes on token boundary, so "+rbac" matches "+rbac:groups=apps" but not "+rbacs".
+func (a *defaultAnnotation) Deprecation(comment string) (string, bool) {
+	comment = strings.TrimSpace(comment)
+	for old, new := range a.Deprecations {
+		if comment == old || strings.HasPrefix(comment, old+":") {
+			return new + strings.TrimPrefix(comment, old), true
+		}
+	}
+	return comment, false
+}
+
 // Parse parses comemnt group into single line comment and validates each token.
 func (a *defaultAnnotation) Parse(comments string) error {
 	for _, comment := range strings.Split(comments, "\n") {
-		comment = strings.TrimSpace(comment)
-		for k := range a.Headers.Union(a.Modules) {
-			if !strings.HasPrefix(comment, prefixName(k)) {
-				continue
-			}
-			// parsing sigle whole line of comment into tokens split by comma (1st level delimiter)
-			// This requires all key-values of same module/submodule should reside in the same comment line
-			tokens := strings.Split(strings.TrimPrefix(comment, "+"), ":")
-			if err := a.parseTokens(tokens); err != nil {
-				return err
-			}
+		if err := a.ParseAt(comment, token.Position{}); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// ParseAt parses single line of comment, duplicate annotations are checked against cardinality of module.
+// Definition of macro is registered, and use of macro is parsed as annotations it expands into.
+// Annotation whose condition does not hold for enabled features is ignored.
+func (a *defaultAnnotation) ParseAt(comment string, pos token.Position) error {
+	comment = strings.TrimSpace(comment)
+	if ok, err := a.define(comment, pos); ok {
+		return err
+	}
+	comment, applies, err := a.Condition(comment)
+	if err != nil {
+		return fmt.Errorf("%s%v", location(pos, ": "), err)
+	}
+	if !applies {
+		return nil
+	}
+	if m, _ := a.macroUse(comment); m != nil {
+		return a.parseMacro(m, comment, pos)
+	}
+	comment, ok, err := a.occurrences.check(a, comment, pos)
+	if err != nil || !ok {
+		return err
+	}
+	i := a.Resolve(comment)
+	if i != nil {
+		if a.disabled.Has(i.Module) {
+			return nil
+		}
+		i.Target, i.Package = a.occurrences.target, a.occurrences.pkg
+		i.Position = pos
+	}
+	for k := range a.Headers.Union(a.Modules) {
+		if !strings.HasPrefix(comment, prefixName(k)) {
+			continue
+		}
+		// parsing sigle whole line of comment into tokens split by comma (1st level delimiter)
+		// This requires all key-values of same module/submodule should reside in the same comment line
+		tokens := strings.Split(strings.TrimPrefix(comment, "+"), ":")
+		if err := a.parseTokens(tokens, a.invoke(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve resolves single comment line into annotation instance. Tokens of registered module are resolved
+// against its submodules, for unregistered module every token but the last is taken as submodule.
+func (a *defaultAnnotation) Resolve(comment string) *Instance {
+	comment = strings.TrimSpace(comment)
+	if !strings.HasPrefix(comment, "+") {
+		return nil
+	}
+	tokens := splitTokens(strings.TrimPrefix(comment, "+"))
+	if len(tokens[0]) == 0 || strings.ContainsAny(tokens[0], " \t") {
+		return nil
+	}
+	i := &Instance{Text: comment}
+	if a.Headers.Has(tokens[0]) && len(tokens) > 1 {
+		i.Header, tokens = tokens[0], tokens[1:]
+	}
+	i.Module, tokens = tokens[0], tokens[1:]
+	m := a.GetModule(i.Module)
+	for len(tokens) > 1 {
+		if m != nil {
+			if !m.HasSubModule(tokens[0]) {
+				break
+			}
+			m = m.SubModules[tokens[0]]
+		}
+		i.SubModules = append(i.SubModules, tokens[0])
+		tokens = tokens[1:]
+	}
+	i.RawElements = strings.Join(tokens, ":")
+	i.Elements = parseElements(i.RawElements)
+	return i
+}
+
 // Complete process annotaion string into Tokens
-func (a *defaultAnnotation) parseTokens(tokens []string) error {
+func (a *defaultAnnotation) parseTokens(tokens []string, do doFunc) error {
 	if a.Headers.Has(tokens[0]) {
 		// competitable for annotations without header starting with "+[module]"
 		tokens = tokens[1:]
 	}
+	if len(tokens) == 0 {
+		// header only, e.g. "+genclient"
+		return nil
+	}
 	if a.Modules.Has(tokens[0]) {
-		return a.GetModule(tokens[0]).parseModule(tokens)
+		return a.GetModule(tokens[0]).parseModule(tokens, do)
+	}
+	if a.lenient {
+		return nil
 	}
 	return fmt.Errorf("annotation %+v format error", tokens)
 }
@@ -93,12 +319,83 @@ type Module struct {
 
 	// Name of the module. It should match the token string in the annotation
 	Name string
-	// Meta holds meta data this module will return or impact. (TODO: fanz) Consider take it into context
+	// Meta holds meta data this module will return or impact. The registering code should keep typed pointer
+	// of its result rather than asserting Meta, and other modules read it by Result
 	Meta interface{}
 	// SubModules represents a recursive architecture of annotation syntax, e.g. [header]:[module]:[submodule1]:[submodule2]:...
 	SubModules map[string]*Module
 	// Do is handler function which defines what this module can do. It takes annotation token passed by Module, and might involve context from runtime
 	Do func(string) error
+
+	// Doc is human readable description of the module, e.g. shown by editors on hover
+	Doc string
+	// Params declares key-value elements accepted by the module. Elements are not checked if it is empty
+	Params []Param
+	// Hooks are invoked on lifecycle events of parsing, e.g. to reset state of module per declaration
+	Hooks Hooks
+	// Requires names modules whose results (Meta) this module reads. Handlers and hooks of required modules
+	// are invoked first, and results are accessed by Result
+	Requires []string
+	// Cardinality declares how many times the module may occur, Repeatable by default
+	Cardinality Cardinality
+	// Merge declares how duplicate occurrences of the module are merged, see Merge
+	Merge Merge
+	// Inherit declares how annotations of the module on a type combine with package defaults, see Inherited
+	Inherit Inherit
+}
+
+// Param declares single key of key-value elements accepted by module
+type Param struct {
+	// Name of the key
+	Name string
+	// Doc is human readable description of the key
+	Doc string
+	// Required is true if the key must present in the annotation
+	Required bool
+	// Values enumerates valid values (case insensitive), and each of multiple values split by semicolon. Any value is valid if empty
+	Values []string
+}
+
+// GetParam returns declared param by given key name
+func (m *Module) GetParam(name string) *Param {
+	for i := range m.Params {
+		if m.Params[i].Name == name {
+			return &m.Params[i]
+		}
+	}
+	return nil
+}
+
+// ValidateElements checks key-value elements against declared params of the module.
+// It returns nil if no params are declared.
+func (m *Module) ValidateElements(elements []Element) []error {
+	if len(m.Params) == 0 {
+		return nil
+	}
+	errs := []error{}
+	found := sets.NewString()
+	for _, e := range elements {
+		p := m.GetParam(e.Key)
+		if p == nil {
+			errs = append(errs, fmt.Errorf("unknown key %q for module %s", e.Key, m.Name))
+			continue
+		}
+		found.Insert(e.Key)
+		if len(p.Values) == 0 {
+			continue
+		}
+		for _, v := range strings.Split(e.Value, ";") {
+			if !containsFold(p.Values, v) && !HasConstRef(v) {
+				errs = append(errs, fmt.Errorf("invalid value %q of key %s, expect one of %v", v, p.Name, p.Values))
+			}
+		}
+	}
+	for _, p := range m.Params {
+		if p.Required && !found.Has(p.Name) {
+			errs = append(errs, fmt.Errorf("missing required key %q for module %s", p.Name, m.Name))
+		}
+	}
+	return errs
 }
 
 // HasSubModule verify if given token string is a valid subresource
@@ -111,12 +408,18 @@ func (m *Module) HasSubModule(name string) bool {
 	return false
 }
 
-func (m *Module) parseModule(tokens []string) error {
+// doFunc invokes handler of module with the key-value elements token
+type doFunc func(m *Module, elements string) error
+
+func (m *Module) parseModule(tokens []string, do doFunc) error {
+	if m.Do == nil && len(tokens) <= 2 {
+		return fmt.Errorf("annotation (%s) format error, module %s requires submodule", tokens, m.Name)
+	}
 	if len(tokens) == 1 {
-		return m.Do(tokens[0])
+		return do(m, tokens[0])
 	}
 	if len(tokens) == 2 {
-		return m.Do(tokens[1])
+		return do(m, tokens[1])
 	}
 	// [module]:[submodule]:[element-values]
 	if len(tokens) > 2 {
@@ -124,16 +427,36 @@ func (m *Module) parseModule(tokens []string) error {
 		if !m.HasSubModule(s) {
 			return fmt.Errorf("annotation (%s) format error, has incorrect submodule %s", tokens, s)
 		}
-		return m.SubModules[s].parseModule(tokens[1:])
+		return m.SubModules[s].parseModule(tokens[1:], do)
+	}
+	return do(m, "")
+}
+
+// ResolveModule returns the registered module of given module path, e.g. "webhook:admission".
+// It returns nil if any module in the path is not registered.
+func ResolveModule(a Annotation, path string) *Module {
+	tokens := splitTokens(path)
+	m := a.GetModule(tokens[0])
+	for _, t := range tokens[1:] {
+		if m == nil || !m.HasSubModule(t) {
+			return nil
+		}
+		m = m.SubModules[t]
 	}
-	return m.Do("")
+	return m
 }
 
 // Build returns initialized default annotation
 func Build() Annotation {
 	return &defaultAnnotation{
-		Headers:   sets.NewString(),
-		Modules:   sets.NewString(),
-		ModuleMap: map[string]*Module{},
+		Headers:      sets.NewString(),
+		Modules:      sets.NewString(),
+		ModuleMap:    map[string]*Module{},
+		Deprecations: map[string]string{},
+		occurrences:  newOccurrences(),
+		disabled:     sets.NewString(),
+		macros:       map[string]*Macro{},
+		runMacros:    map[string]*Macro{},
+		features:     map[string]bool{},
 	}
 }
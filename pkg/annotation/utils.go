@@ -10,6 +10,21 @@ func prefixName(name string) string {
 	return "+" + name
 }
 
+// splitTokens splits annotation string into tokens by colon (1st level delimiter)
+func splitTokens(s string) []string {
+	return strings.Split(s, ":")
+}
+
+// containsFold returns true if list contains s under case folding
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
 // isGoFile filters files from parsing.
 func isGoFile(f os.FileInfo) bool {
 	// ignore non-Go or Go test files
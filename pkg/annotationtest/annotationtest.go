@@ -0,0 +1,183 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package annotationtest helps authors of annotation modules test them: it builds registries with modules under
+// test, parses inline Go source, asserts annotation instances, errors and diagnostics with their positions,
+// and compares generated outputs with golden files, e.g.
+//
+//	func TestRBAC(t *testing.T) {
+//		a := annotationtest.Registry(rbac.AddToAnnotation)
+//		r := annotationtest.Parse(t, a, `
+//			package foo
+//
+//			// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get
+//			type Foo struct{}
+//		`)
+//		r.ExpectInstances("3:1 Foo +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get")
+//		annotationtest.Golden(t, "testdata/role.yaml", manifest)
+//	}
+package annotationtest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/diff"
+	"github.com/fanzhangio/go-annotation/pkg/lsp"
+)
+
+// Filename is the name of inline source in positions, e.g. "test.go:3:1"
+const Filename = "test.go"
+
+var update = flag.Bool("update-golden", false, "update golden files of annotationtest.Golden instead of comparing with them")
+
+// Registry returns registry with default headers and deprecations of annotation.GetAnnotation, and modules registered
+// by given functions, e.g. rbac.AddToAnnotation. Panics of module handlers are reported as errors.
+func Registry(register ...func(annotation.Annotation) annotation.Annotation) annotation.Annotation {
+	a := annotation.AddDefaults(annotation.Build())
+	a.Intercept(annotation.Recover())
+	for _, r := range register {
+		a = r(a)
+	}
+	return a
+}
+
+// Module returns function registering given module, for Registry
+func Module(m *annotation.Module) func(annotation.Annotation) annotation.Annotation {
+	return func(a annotation.Annotation) annotation.Annotation {
+		a.Module(m)
+		return a
+	}
+}
+
+// Source returns inline source without indentation common to its lines and without leading empty lines, so that
+// source is written indented in tests, and positions count from its first line.
+func Source(src string) string {
+	lines := strings.Split(strings.TrimLeft(src, "\n"), "\n")
+	indent := ""
+	for n, l := range lines {
+		if len(strings.TrimSpace(l)) == 0 {
+			continue
+		}
+		prefix := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		if n == 0 || len(indent) == 0 || len(prefix) < len(indent) {
+			indent = prefix
+		}
+	}
+	for n, l := range lines {
+		lines[n] = strings.TrimPrefix(l, indent)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), " \t")
+}
+
+// Result is result of parsing inline source
+type Result struct {
+	t testing.TB
+	// Instances are annotations indexed as parsed, see annotation.IndexByFile
+	Instances []*annotation.Instance
+	// Err is the first error of module handlers or lifecycle hooks
+	Err error
+}
+
+// Parse parses annotations of inline source by module handlers of registry a, as file Filename. See Source.
+func Parse(t testing.TB, a annotation.Annotation, src string) *Result {
+	idx := annotation.NewIndex()
+	err := annotation.IndexByFile(token.NewFileSet(), Filename, Source(src), a, idx)
+	return &Result{t: t, Instances: idx.Instances(), Err: err}
+}
+
+// ExpectInstances fails the test unless the instances are the expected ones in order, formatted as
+// "<line>:<column> <declaration> <annotation>", e.g. "3:1 Foo +kubebuilder:rbac:groups=apps,verbs=get".
+func (r *Result) ExpectInstances(exp ...string) {
+	r.t.Helper()
+	got := []string{}
+	for _, i := range r.Instances {
+		got = append(got, fmt.Sprintf("%d:%d %s %s", i.Position.Line, i.Position.Column, i.Target, i.Text))
+	}
+	if !reflect.DeepEqual(got, exp) && (len(got) > 0 || len(exp) > 0) {
+		r.t.Errorf("expected instances\n\t%s\ngot\n\t%s", strings.Join(exp, "\n\t"), strings.Join(got, "\n\t"))
+	}
+}
+
+// ExpectError fails the test unless parsing failed by error of given message. Errors of conflicting annotations and
+// panics of handlers start with position of the annotation, e.g. "test.go:3:1: ...". Empty message expects no error.
+func (r *Result) ExpectError(exp string) {
+	r.t.Helper()
+	got := ""
+	if r.Err != nil {
+		got = r.Err.Error()
+	}
+	if got != exp {
+		r.t.Errorf("expected error %q, got %q", exp, got)
+	}
+}
+
+// ExpectDiagnostics fails the test unless the language server diagnoses inline source by the expected diagnostics
+// in order, formatted as "<line>:<column> <severity>: <message>", e.g. "3:4 warning: deprecated spelling, use ...".
+func ExpectDiagnostics(t testing.TB, a annotation.Annotation, src string, exp ...string) {
+	t.Helper()
+	severities := map[int]string{lsp.SeverityError: "error", lsp.SeverityWarning: "warning"}
+	got := []string{}
+	for _, d := range lsp.NewServer(func() annotation.Annotation { return a }).Diagnose(Source(src)) {
+		got = append(got, fmt.Sprintf("%d:%d %s: %s", d.Range.Start.Line+1, d.Range.Start.Character+1, severities[d.Severity], d.Message))
+	}
+	if !reflect.DeepEqual(got, exp) && (len(got) > 0 || len(exp) > 0) {
+		t.Errorf("expected diagnostics\n\t%s\ngot\n\t%s", strings.Join(exp, "\n\t"), strings.Join(got, "\n\t"))
+	}
+}
+
+// Golden fails the test unless got equals content of golden file of given path, with unified diff of them.
+// With flag -update-golden, the golden file is written instead, e.g. go test ./... -args -update-golden.
+// Golden files are read and written on the OS filesystem, not annotation.Fs, since outputs may be generated in memory.
+func Golden(t testing.TB, path string, got []byte) {
+	t.Helper()
+	fs := afero.NewOsFs()
+	if *update {
+		if err := fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := afero.WriteFile(fs, path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	exp, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		t.Errorf("golden file %s does not exist, run test with -update-golden to write it", path)
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(exp, got) {
+		return
+	}
+	d := diff.Unified(path, exp, got)
+	if len(d) == 0 {
+		d = "output differs in formatting only\n"
+	}
+	t.Errorf("output differs from golden file, run test with -update-golden to update it\n%s", d)
+}
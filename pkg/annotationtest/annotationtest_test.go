@@ -0,0 +1,126 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotationtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// recorder records failures of assertions instead of failing the test
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestParse(t *testing.T) {
+	done := []string{}
+	a := Registry(Module(&annotation.Module{
+		Name: "rbac",
+		Do: func(s string) error {
+			if strings.Contains(s, "verbs=*") {
+				return fmt.Errorf("wildcard verbs are not allowed")
+			}
+			done = append(done, s)
+			return nil
+		},
+		Params: []annotation.Param{{Name: "groups"}, {Name: "verbs", Required: true}},
+	}))
+	src := `
+		package foo
+
+		// +kubebuilder:rbac:groups=apps,verbs=get
+		// +rbac:groups=batch,verbs=list
+		type Foo struct{}
+	`
+	if exp := "package foo\n\n// +kubebuilder:rbac:groups=apps,verbs=get\n// +rbac:groups=batch,verbs=list\ntype Foo struct{}\n"; Source(src) != exp {
+		t.Errorf("expect source without indentation %q, got %q", exp, Source(src))
+	}
+	r := Parse(t, a, src)
+	r.ExpectError("")
+	r.ExpectInstances(
+		"3:1 Foo +kubebuilder:rbac:groups=apps,verbs=get",
+		"4:1 Foo +rbac:groups=batch,verbs=list",
+	)
+	if !reflect.DeepEqual(done, []string{"groups=apps,verbs=get", "groups=batch,verbs=list"}) {
+		t.Errorf("expect annotations handled by module, got %v", done)
+	}
+
+	rec := &recorder{TB: t}
+	r = Parse(rec, a, `
+		package foo
+
+		// +kubebuilder:rbac:groups=apps,verbs=*
+		type Foo struct{}
+	`)
+	r.ExpectError("wildcard verbs are not allowed")
+	// nothing is indexed once parsing fails
+	r.ExpectInstances("3:1 Foo +kubebuilder:rbac:groups=apps,verbs=*")
+	if len(rec.failures) != 1 || !strings.HasPrefix(rec.failures[0], "expected instances") {
+		t.Errorf("expect failure of missing instances, got %v", rec.failures)
+	}
+
+	ExpectDiagnostics(t, a, `
+		package foo
+
+		// +rbac:groups=apps,verbs=get
+		// +kubebuilder:rbac:groups=apps
+		type Foo struct{}
+	`,
+		"3:4 warning: deprecated spelling, use +kubebuilder:rbac:groups=apps,verbs=get",
+		`4:4 error: missing required key "verbs" for module rbac`,
+	)
+}
+
+func TestGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "testdata", "role.yaml")
+
+	rec := &recorder{TB: t}
+	Golden(rec, path, []byte("verbs: [get]\n"))
+	if len(rec.failures) != 1 || !strings.Contains(rec.failures[0], "-update-golden") {
+		t.Errorf("expect failure of missing golden file, got %v", rec.failures)
+	}
+
+	*update = true
+	Golden(t, path, []byte("verbs: [get]\n"))
+	*update = false
+	Golden(t, path, []byte("verbs: [get]\n"))
+
+	rec = &recorder{TB: t}
+	Golden(rec, path, []byte("verbs: [get, list]\n"))
+	if len(rec.failures) != 1 || !strings.Contains(rec.failures[0], "+- list\n") {
+		t.Errorf("expect failure with diff of golden file, got %v", rec.failures)
+	}
+}
@@ -73,7 +73,7 @@ func (b *APIs) parseAPIs() {
 					ShortName:      resource.ShortName,
 					CRD:            resource.CRD,
 				}
-				parseDoc(resource, apiResource)
+				b.parseDoc(resource, apiResource)
 				apiVersion.Resources[kind] = apiResource
 				// Set the package for the api version
 				apiVersion.Pkg = b.context.Universe[resource.Type.Name.Package]
@@ -131,7 +131,7 @@ func (b *APIs) parseStructs(apigroup *codegen.APIGroup) {
 		}
 
 		if next.Resource != nil {
-			result.NonNamespaced = IsNonNamespaced(next.Type)
+			result.NonNamespaced = b.types.isNonNamespaced(next.Type)
 		}
 
 		if b.genDeepCopy(next.Type) {
@@ -280,8 +280,8 @@ func (b *APIs) genDeepCopy(c *types.Type) bool {
 	return comments.hasTag("subresource-request")
 }
 
-func parseDoc(resource, apiResource *codegen.APIResource) {
-	if HasDocAnnotation(resource.Type) {
+func (b *APIs) parseDoc(resource, apiResource *codegen.APIResource) {
+	if b.types.hasDocAnnotation(resource.Type) {
 		resource.DocAnnotation = getDocAnnotation(resource.Type, "warning", "note")
 		apiResource.DocAnnotation = resource.DocAnnotation
 	}
@@ -0,0 +1,186 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parse
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/spf13/afero"
+	"k8s.io/gengo/types"
+)
+
+// withOverlay returns comments of declaration followed by annotations of overlays registered in default annotation,
+// with constant references expanded. Declaration is named "Type" or "Type.Field" in package of given path.
+func (consts constResolvers) withOverlay(pkg, name string, comments []string) []string {
+	result := append([]string{}, comments...)
+	for _, l := range annotation.GetAnnotation().OverlayLines(pkg, name) {
+		result = append(result, l.Text)
+	}
+	return consts.expand(pkg, result)
+}
+
+// adapted returns comments rewritten by adapters registered in default annotation, e.g. markers of controller-gen,
+// see annotation.Adapter
+func adapted(comments []string) []string {
+	result := make([]string, 0, len(comments))
+	for _, c := range comments {
+		lines, err := annotation.GetAnnotation().Adapted(c)
+		if err != nil {
+			log.Fatalf("Could not adapt annotation %s: %v", c, err)
+		}
+		result = append(result, lines...)
+	}
+	return result
+}
+
+// memberLines returns comments of struct field followed by annotations of its struct tag, see annotation.TagSource
+func memberLines(m types.Member) []string {
+	return append(append([]string{}, m.CommentLines...), annotation.TagLines(annotation.GetAnnotation(), m.Tags)...)
+}
+
+// applied drops annotations whose conditions do not hold for features enabled in default annotation, and strips
+// conditions from the others. It returns false if "+kubebuilder:field" annotation does not apply, i.e. the field
+// is omitted from the schema.
+func applied(comments []string) ([]string, bool) {
+	ann := annotation.GetAnnotation()
+	result := make([]string, 0, len(comments))
+	included := true
+	for _, c := range comments {
+		stripped, applies, err := ann.Condition(c)
+		if err != nil {
+			log.Fatalf("Could not evaluate condition of %s: %v", c, err)
+		}
+		if !applies {
+			if i := ann.Resolve(c); i != nil && i.Module == "field" {
+				included = false
+			}
+			continue
+		}
+		result = append(result, stripped)
+	}
+	return result, included
+}
+
+// sourceComment is a line of doc comment of type declaration at its position in source
+type sourceComment struct {
+	text string
+	pos  token.Position
+}
+
+// sourceComments returns lines of doc comments of type declarations by type name, and of package doc by empty name,
+// read from Go files in the source directory of package p. Packages without source directory, e.g. loaded for tests,
+// have no comments.
+func sourceComments(p *types.Package) map[string][]sourceComment {
+	result := map[string][]sourceComment{}
+	if len(p.SourcePath) == 0 {
+		return result
+	}
+	infos, err := afero.ReadDir(annotation.Fs(), p.SourcePath)
+	if err != nil {
+		return result
+	}
+	fset := token.NewFileSet()
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".go") || strings.HasSuffix(info.Name(), "_test.go") {
+			continue
+		}
+		path := filepath.Join(p.SourcePath, info.Name())
+		src, err := afero.ReadFile(annotation.Fs(), path)
+		if err != nil {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		if f.Doc != nil {
+			result[""] = append(result[""], docComments(fset, f.Doc)...)
+		}
+		for _, decl := range f.Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(d.Specs) == 1 {
+					doc = d.Doc
+				}
+				if doc == nil {
+					continue
+				}
+				result[ts.Name.Name] = append(result[ts.Name.Name], docComments(fset, doc)...)
+			}
+		}
+	}
+	return result
+}
+
+// docComments returns lines of comment group g, whose text is stripped as ast.CommentGroup.Text does, which gives
+// comment lines of types and packages
+func docComments(fset *token.FileSet, g *ast.CommentGroup) []sourceComment {
+	result := []sourceComment{}
+	for _, c := range g.List {
+		text := strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), " ")
+		result = append(result, sourceComment{text: text, pos: fset.Position(c.Slash)})
+	}
+	return result
+}
+
+// commentPositions returns source positions of comment lines of t, zero positions for lines not found in source
+func (x *typeIndex) commentPositions(u types.Universe, t *types.Type) []token.Position {
+	return positions(t.CommentLines, x.sourceComments(u[t.Name.Package])[t.Name.Name])
+}
+
+// docPositions returns source positions of lines of package doc of p, see commentPositions
+func (x *typeIndex) docPositions(p *types.Package) []token.Position {
+	return positions(p.DocComments, x.sourceComments(p)[""])
+}
+
+// sourceComments returns doc comments in source of package p cached by the index
+func (x *typeIndex) sourceComments(p *types.Package) map[string][]sourceComment {
+	if p == nil {
+		return nil
+	}
+	sources, ok := x.sources[p.Path]
+	if !ok {
+		sources = sourceComments(p)
+		x.sources[p.Path] = sources
+	}
+	return sources
+}
+
+// positions returns positions of given lines matched in order against comments in source
+func positions(lines []string, comments []sourceComment) []token.Position {
+	result := make([]token.Position, len(lines))
+	for n, line := range lines {
+		for m, c := range comments {
+			if strings.TrimSpace(c.text) == strings.TrimSpace(line) {
+				result[n], comments = c.pos, comments[m+1:]
+				break
+			}
+		}
+	}
+	return result
+}
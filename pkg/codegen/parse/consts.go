@@ -0,0 +1,54 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parse
+
+import (
+	"log"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// constResolvers caches resolvers of Go constants by package path. It is scoped to a load, see typeIndex, so
+// constants changed between loads in the same process are resolved again.
+type constResolvers map[string]*annotation.ConstResolver
+
+// expand replaces references to Go constants in comments by their values, e.g. "${maxReplicas}" in
+// "+kubebuilder:validation:Maximum=${maxReplicas}". Constants are resolved in the package of given path.
+// Comments are adapted first, see adapted.
+func (consts constResolvers) expand(pkg string, comments []string) []string {
+	comments = adapted(comments)
+	result := make([]string, 0, len(comments))
+	for _, c := range comments {
+		if annotation.HasConstRef(c) {
+			r, ok := consts[pkg]
+			if !ok {
+				var err error
+				if r, err = annotation.ImportConstResolver(pkg); err != nil {
+					log.Fatalf("Could not resolve constants of package %s: %v", pkg, err)
+				}
+				consts[pkg] = r
+			}
+			expanded, err := r.Expand(c)
+			if err != nil {
+				log.Fatalf("Could not expand annotation %s of package %s: %v", c, pkg, err)
+			}
+			c = expanded
+		}
+		result = append(result, c)
+	}
+	return result
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/codegen"
+	"github.com/ghodss/yaml"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/gengo/types"
+)
+
+// Explanation explains CRD generated for API resource type by annotations of the type and its fields
+type Explanation struct {
+	// Type is the full name of the type, e.g. "example.com/pkg/apis/ship/v1.Frigate"
+	Type string `json:"type"`
+	// Annotations are annotations of the type and its fields, after package defaults, overlays, constants and macros
+	// are applied
+	Annotations []ExplainedAnnotation `json:"annotations"`
+	// Fragments are parts of the CRD, linked to annotations causing them
+	Fragments []Fragment `json:"fragments"`
+}
+
+// ExplainedAnnotation is annotation of the type or of its field, and the module handling it
+type ExplainedAnnotation struct {
+	// ID refers to the annotation from fragments, starting from 1
+	ID int `json:"id"`
+	// Target is the type or field, e.g. "Frigate" or "FrigateSpec.Replicas", or the package of inherited defaults,
+	// e.g. "package v1"
+	Target string `json:"target"`
+	Text   string `json:"text"`
+	// Macro is the use of macro the annotation is expanded from, if any
+	Macro string `json:"macro,omitempty"`
+	// Module is the path of module handling the annotation, empty if no registered module handles it
+	Module string `json:"module,omitempty"`
+	// Doc is the doc of the module
+	Doc string `json:"doc,omitempty"`
+	// Elements are the decoded key-value elements
+	Elements []annotation.Element `json:"elements,omitempty"`
+	// Applied is false if the condition of the annotation does not hold for enabled features
+	Applied bool `json:"applied"`
+}
+
+// Fragment is part of generated CRD
+type Fragment struct {
+	// Path is the path of the fragment in the CRD, e.g. "spec.names.plural"
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+	// Annotations are IDs of annotations causing the fragment, it is empty if the fragment is defaulted
+	Annotations []int `json:"annotations"`
+}
+
+// schemaPath is the path of OpenAPI schema in CRD
+const schemaPath = "spec.validation.openAPIV3Schema"
+
+// Explain explains CRD of API resource type named by "<pkg>.<Type>", where pkg is either the import path
+// or the last element of it, e.g. "v1.Frigate". Annotations are resolved by the default annotation, which has
+// modules registered by parsing the resources.
+func (b *APIs) Explain(name string) (*Explanation, error) {
+	r, err := b.lookupResource(name)
+	if err != nil {
+		return nil, err
+	}
+	t := r.Type
+	e := &explainer{Explanation: Explanation{Type: t.Name.String(), Annotations: []ExplainedAnnotation{}, Fragments: []Fragment{}}, consts: b.types.consts}
+
+	comments := e.consts.withOverlay(t.Name.Package, t.Name.Name, t.CommentLines)
+	typeIDs := e.explain("package "+filepath.Base(t.Name.Package), b.types.inherited(t, comments))
+	typeIDs = append(typeIDs, e.explain(t.Name.Name, comments)...)
+	spec := r.CRD.Spec
+	e.fragment("spec.names.kind", spec.Names.Kind, nil)
+	e.fragment("spec.names.plural", spec.Names.Plural, e.withKey(typeIDs, "resource", "path"))
+	if len(spec.Names.ShortNames) > 0 {
+		e.fragment("spec.names.shortNames", spec.Names.ShortNames, e.withKey(typeIDs, "resource", "shortName"))
+	}
+	if len(spec.Names.Categories) > 0 {
+		e.fragment("spec.names.categories", spec.Names.Categories, e.withKey(typeIDs, "categories", ""))
+	}
+	e.fragment("spec.scope", spec.Scope, e.withKey(typeIDs, "nonNamespaced", ""))
+	if s := spec.Subresources; s != nil {
+		if s.Status != nil {
+			e.fragment("spec.subresources.status", s.Status, e.withKey(typeIDs, "subresource", ""))
+		}
+		if s.Scale != nil {
+			e.fragment("spec.subresources.scale", s.Scale, e.withKey(typeIDs, "subresource:scale", ""))
+		}
+	}
+	// printer columns are appended in order of their annotations
+	columns := e.withKey(typeIDs, "printcolumn", "")
+	for k, c := range spec.AdditionalPrinterColumns {
+		ids := []int{}
+		if k < len(columns) {
+			ids = append(ids, columns[k])
+		}
+		e.fragment(fmt.Sprintf("spec.additionalPrinterColumns[%d]", k), c, ids)
+	}
+	if ids := e.withKey(typeIDs, "validation", ""); len(ids) > 0 {
+		e.fragment(schemaPath, shallow(r.JSONSchemaProps), ids)
+	}
+
+	e.explainMembers(t, schemaPath, r.JSONSchemaProps, sets.NewString())
+	return &e.Explanation, nil
+}
+
+// lookupResource returns API resource of type named by "<pkg>.<Type>"
+func (b *APIs) lookupResource(name string) (*codegen.APIResource, error) {
+	found := []*codegen.APIResource{}
+	for _, versions := range b.ByGroupVersionKind {
+		for _, kinds := range versions {
+			for _, r := range kinds {
+				if r.Type == nil {
+					continue
+				}
+				full := r.Type.Name.String()
+				short := filepath.Base(r.Type.Name.Package) + "." + r.Type.Name.Name
+				if name == full || name == short {
+					found = append(found, r)
+				}
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%s is not an API resource type, expect <pkg>.<Type>, e.g. v1.Frigate", name)
+	case 1:
+		return found[0], nil
+	}
+	names := []string{}
+	for _, r := range found {
+		names = append(names, r.Type.Name.String())
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("%s is ambiguous, expect one of %s", name, strings.Join(names, ", "))
+}
+
+// explainer collects annotations and fragments of an explanation
+type explainer struct {
+	Explanation
+	// consts caches resolvers of constants of the load explained
+	consts constResolvers
+}
+
+// explain adds annotations of given comments of target, and returns their IDs
+func (e *explainer) explain(target string, comments []string) []int {
+	ann := annotation.GetAnnotation()
+	ids := []int{}
+	for _, c := range comments {
+		stripped, applies, err := ann.Condition(c)
+		if err != nil {
+			stripped, applies = c, false
+		}
+		macro := ""
+		lines, err := ann.Expand(stripped, token.Position{})
+		if err != nil {
+			lines = []annotation.Line{{Text: stripped}}
+		}
+		if len(lines) != 1 || lines[0].Text != strings.TrimSpace(stripped) {
+			macro = strings.TrimSpace(stripped)
+		}
+		for _, l := range lines {
+			i := ann.Resolve(l.Text)
+			if i == nil || i.Module == annotation.DefineModule {
+				continue
+			}
+			a := ExplainedAnnotation{
+				ID:       len(e.Annotations) + 1,
+				Target:   target,
+				Text:     i.Text,
+				Macro:    macro,
+				Elements: i.Elements,
+				Applied:  applies,
+			}
+			if m := module(ann, i); m != nil {
+				a.Module, a.Doc = i.Path(), m.Doc
+			}
+			e.Annotations = append(e.Annotations, a)
+			ids = append(ids, a.ID)
+		}
+	}
+	return ids
+}
+
+// module returns registered module handling annotation instance, or nil if there is none
+func module(ann annotation.Annotation, i *annotation.Instance) *annotation.Module {
+	m := ann.GetModule(i.Module)
+	for _, name := range i.SubModules {
+		if m == nil {
+			return nil
+		}
+		m = m.SubModules[name]
+	}
+	return m
+}
+
+// withKey returns IDs of applied annotations of module path, which have given element key if it is not empty
+func (e *explainer) withKey(ids []int, path, key string) []int {
+	result := []int{}
+	for _, id := range ids {
+		a := e.Annotations[id-1]
+		if !a.Applied || a.Module != path {
+			continue
+		}
+		if len(key) > 0 && !hasKey(a.Elements, key) {
+			continue
+		}
+		result = append(result, id)
+	}
+	return result
+}
+
+func hasKey(elements []annotation.Element, key string) bool {
+	for _, el := range elements {
+		if el.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *explainer) fragment(path string, value interface{}, ids []int) {
+	if ids == nil {
+		ids = []int{}
+	}
+	e.Fragments = append(e.Fragments, Fragment{Path: path, Value: value, Annotations: ids})
+}
+
+// explainMembers adds annotations of fields of t, and schema fragments of annotated fields as getMembers
+// generates them. Schemas of API types of Kubernetes are not explained.
+func (e *explainer) explainMembers(t *types.Type, path string, props v1beta1.JSONSchemaProps, found sets.String) {
+	if found.Has(t.Name.String()) || strings.HasPrefix(t.Name.String(), "k8s.io/api") {
+		return
+	}
+	found.Insert(t.Name.String())
+	defer found.Delete(t.Name.String())
+
+	for _, member := range t.Members {
+		tags := jsonRegex.FindStringSubmatch(member.Tags)
+		if len(tags) == 0 {
+			continue
+		}
+		target := t.Name.Name + "." + member.Name
+		ids := e.explain(target, e.consts.withOverlay(t.Name.Package, target, memberLines(member)))
+		ts := strings.Split(tags[1], ",")
+		name := member.Name
+		if len(ts) > 0 && len(ts[0]) > 0 {
+			name = ts[0]
+		}
+		if len(ts) > 1 && ts[1] == "inline" {
+			e.explainMembers(member.Type, path, props, found)
+			continue
+		}
+		fieldPath := path + ".properties." + name
+		field, ok := props.Properties[name]
+		if !ok {
+			// omitted from the schema, e.g. by +kubebuilder:field:if=enterprise
+			if len(ids) > 0 {
+				e.fragment(fieldPath, nil, ids)
+			}
+			continue
+		}
+		if len(ids) > 0 {
+			e.fragment(fieldPath, shallow(field), ids)
+		}
+
+		mt := member.Type
+		for mt.Kind == types.Pointer {
+			mt = mt.Elem
+		}
+		if mt.Kind == types.Slice && field.Items != nil && field.Items.Schema != nil {
+			mt, field, fieldPath = mt.Elem, *field.Items.Schema, fieldPath+".items"
+			for mt.Kind == types.Pointer {
+				mt = mt.Elem
+			}
+		}
+		if mt.Kind == types.Struct {
+			e.explainMembers(mt, fieldPath, field, found)
+		}
+	}
+}
+
+// shallow returns schema without nested properties and items, which are explained as fragments of their own
+func shallow(props v1beta1.JSONSchemaProps) v1beta1.JSONSchemaProps {
+	props.Properties = nil
+	props.Items = nil
+	return props
+}
+
+// Write writes explanation to w in given format, which is one of "text", "json" or "yaml"
+func (e *Explanation) Write(w io.Writer, format string) error {
+	var b []byte
+	var err error
+	switch format {
+	case "text":
+		b, err = e.text()
+	case "json":
+		b, err = json.MarshalIndent(e, "", "  ")
+		b = append(b, '\n')
+	case "yaml":
+		b, err = yaml.Marshal(e)
+	default:
+		return fmt.Errorf("unknown explain format %q, expect text, json or yaml", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (e *Explanation) text() ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "type %s\n", e.Type)
+	b.WriteString("annotations:\n")
+	for _, a := range e.Annotations {
+		fmt.Fprintf(&b, "\t[%d] %s: %s", a.ID, a.Target, a.Text)
+		if !a.Applied {
+			b.WriteString(" (condition does not hold)")
+		}
+		b.WriteString("\n")
+		if len(a.Macro) > 0 {
+			fmt.Fprintf(&b, "\t\texpanded from %s\n", a.Macro)
+		}
+		if len(a.Module) > 0 {
+			fmt.Fprintf(&b, "\t\tmodule %s: %s\n", a.Module, a.Doc)
+		} else {
+			b.WriteString("\t\tno module handles the annotation\n")
+		}
+		for _, el := range a.Elements {
+			if len(el.Value) == 0 {
+				fmt.Fprintf(&b, "\t\t%s\n", el.Key)
+				continue
+			}
+			fmt.Fprintf(&b, "\t\t%s=%s\n", el.Key, el.Value)
+		}
+	}
+	b.WriteString("fragments:\n")
+	for _, f := range e.Fragments {
+		v, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "\t%s: %s", f.Path, v)
+		if len(f.Annotations) == 0 {
+			b.WriteString(" (default)")
+		}
+		for _, id := range f.Annotations {
+			fmt.Fprintf(&b, " [%d]", id)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
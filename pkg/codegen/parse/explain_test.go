@@ -0,0 +1,111 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parse
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/codegen"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/gengo/types"
+)
+
+func TestExplain(t *testing.T) {
+	AddToAnnotation(annotation.GetAnnotation())
+	pkg := "example.com/pkg/apis/ship/v1"
+	spec := &types.Type{Name: types.Name{Package: pkg, Name: "FrigateSpec"}, Kind: types.Struct, Members: []types.Member{
+		{Name: "Replicas", Tags: `json:"replicas"`, Type: types.Int32, CommentLines: []string{"+kubebuilder:validation:Minimum=0"}},
+	}}
+	frigate := &types.Type{Name: types.Name{Package: pkg, Name: "Frigate"}, Kind: types.Struct, Members: []types.Member{
+		{Name: "Spec", Tags: `json:"spec,omitempty"`, Type: spec},
+	}, CommentLines: []string{
+		"Frigate is the Schema for the frigates API",
+		"+kubebuilder:resource:path=frigates,shortName=fg",
+		"+kubebuilder:subresource:status",
+		"+kubebuilder:printcolumn:name=replicas,type=integer,JSONPath=.spec.replicas",
+	}}
+
+	minimum := float64(0)
+	r := &codegen.APIResource{Type: frigate, JSONSchemaProps: v1beta1.JSONSchemaProps{
+		Properties: map[string]v1beta1.JSONSchemaProps{
+			"spec": {Type: "object", Properties: map[string]v1beta1.JSONSchemaProps{
+				"replicas": {Type: "integer", Format: "int32", Minimum: &minimum},
+			}},
+		},
+	}}
+	r.CRD.Spec = v1beta1.CustomResourceDefinitionSpec{
+		Names:        v1beta1.CustomResourceDefinitionNames{Kind: "Frigate", Plural: "frigates", ShortNames: []string{"fg"}, Categories: []string{"ships"}},
+		Scope:        "Namespaced",
+		Subresources: &v1beta1.CustomResourceSubresources{Status: &v1beta1.CustomResourceSubresourceStatus{}},
+		AdditionalPrinterColumns: []v1beta1.CustomResourceColumnDefinition{
+			{Name: "replicas", Type: "integer", JSONPath: ".spec.replicas"},
+		},
+	}
+	b := &APIs{ByGroupVersionKind: map[string]map[string]map[string]*codegen.APIResource{
+		"ship": {"v1": {"Frigate": r}},
+	}, types: newTypeIndex(nil, nil)}
+	b.types.docs[pkg] = []string{"+groupName=ship.example.com", "+kubebuilder:categories:ships"}
+
+	if _, err := b.Explain("v1.Destroyer"); err == nil {
+		t.Errorf("expect error explaining unknown type")
+	}
+	e, err := b.Explain("v1.Frigate")
+	if err != nil {
+		t.Fatalf("Explain should have succeeded, but got error: %v", err)
+	}
+	modules := []string{}
+	for _, a := range e.Annotations {
+		modules = append(modules, a.Target+" "+a.Module)
+	}
+	exp := []string{"package v1 categories", "Frigate resource", "Frigate subresource", "Frigate printcolumn", "FrigateSpec.Replicas validation"}
+	if !reflect.DeepEqual(modules, exp) {
+		t.Errorf("expect annotations %v, got %v", exp, modules)
+	}
+	if v, _ := (&annotation.Instance{Elements: e.Annotations[1].Elements}).Value("shortName"); v != "fg" {
+		t.Errorf("expect decoded shortName fg, got %q", v)
+	}
+
+	fragments := map[string][]int{}
+	for _, f := range e.Fragments {
+		fragments[f.Path] = f.Annotations
+	}
+	expFragments := map[string][]int{
+		"spec.names.kind":                                   {},
+		"spec.names.plural":                                 {2},
+		"spec.names.shortNames":                             {2},
+		"spec.names.categories":                             {1},
+		"spec.scope":                                        {},
+		"spec.subresources.status":                          {3},
+		"spec.additionalPrinterColumns[0]":                  {4},
+		schemaPath + ".properties.spec.properties.replicas": {5},
+	}
+	if !reflect.DeepEqual(fragments, expFragments) {
+		t.Errorf("expect fragments %v, got %v", expFragments, fragments)
+	}
+
+	var buf bytes.Buffer
+	if err := e.Write(&buf, "text"); err != nil {
+		t.Fatalf("Write should have succeeded, but got error: %v", err)
+	}
+	if line := "\tspec.scope: \"Namespaced\" (default)\n"; !strings.Contains(buf.String(), line) {
+		t.Errorf("expect text containing %q, got\n%s", line, buf.String())
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parse
+
+import (
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"k8s.io/gengo/types"
+)
+
+// typeIndex indexes annotations of types by type name, and holds docs of their packages whose annotations are
+// defaults of API resource types of the packages. It is built once per load by APIs, types out of the load are
+// indexed on first lookup.
+type typeIndex struct {
+	*annotation.Index
+	// docs holds comments of package docs by package path with constants expanded
+	docs map[string][]string
+	// consts caches resolvers of constants of the load
+	consts constResolvers
+	// sources caches doc comments of types in source by package path, see commentPositions
+	sources map[string]map[string][]sourceComment
+}
+
+// newTypeIndex indexes annotations of given types and docs of their packages in universe u. Modules should be
+// registered before, since defaults of package docs are inherited by modules, see annotation.Inherited.
+func newTypeIndex(u types.Universe, ts []*types.Type) *typeIndex {
+	x := &typeIndex{Index: annotation.NewIndex(), docs: map[string][]string{}, consts: constResolvers{},
+		sources: map[string]map[string][]sourceComment{}}
+	for _, t := range ts {
+		pkg := t.Name.Package
+		if _, ok := x.docs[pkg]; ok {
+			continue
+		}
+		x.docs[pkg] = nil
+		if p := u[pkg]; p != nil {
+			x.docs[pkg] = x.consts.expand(pkg, p.DocComments)
+		}
+	}
+	for _, t := range ts {
+		x.add(t)
+	}
+	return x
+}
+
+// add adds annotations in the comments of t, following defaults inherited from its package, into the index.
+// Comments separated from the type by a blank line are not indexed, see isNonNamespaced.
+func (x *typeIndex) add(t *types.Type) {
+	ann := annotation.GetAnnotation()
+	comments := x.consts.withOverlay(t.Name.Package, t.Name.Name, t.CommentLines)
+	comments, _ = applied(append(x.inherited(t, comments), comments...))
+	for _, c := range comments {
+		if i := ann.Resolve(c); i != nil {
+			i.Target = t.Name.String()
+			x.Add(i)
+		}
+	}
+}
+
+// inherited returns annotations of package doc inherited by t with given comments, see annotation.Inherited.
+// Only API resource types inherit defaults, which are declared by resource annotation or by object metadata.
+func (x *typeIndex) inherited(t *types.Type, comments []string) []string {
+	defaults := x.docs[t.Name.Package]
+	if len(defaults) == 0 {
+		return nil
+	}
+	ann := annotation.GetAnnotation()
+	resource := hasObjectMeta(t)
+	for _, c := range comments {
+		if i := ann.Resolve(c); i != nil && i.Module == "resource" {
+			resource = true
+		}
+	}
+	if !resource {
+		return nil
+	}
+	return annotation.Inherited(ann, defaults, comments)
+}
+
+// lookup returns annotations of given module path on t
+func (x *typeIndex) lookup(t *types.Type, path string) []*annotation.Instance {
+	if !x.HasTarget(t.Name.String()) {
+		x.add(t)
+	}
+	return x.Lookup(t.Name.String(), path)
+}
+
+// has returns true if t is annotated by given module path, e.g. "subresource:scale"
+func (x *typeIndex) has(t *types.Type, path string) bool {
+	return len(x.lookup(t, path)) > 0
+}
+
+// hasElement returns true if t is annotated by given module path with element key,
+// e.g. "status" of "+kubebuilder:subresource:status"
+func (x *typeIndex) hasElement(t *types.Type, path, key string) bool {
+	for _, i := range x.lookup(t, path) {
+		if _, ok := i.Value(key); ok {
+			return true
+		}
+	}
+	return false
+}
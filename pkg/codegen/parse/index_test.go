@@ -0,0 +1,53 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parse
+
+import (
+	"testing"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"k8s.io/gengo/types"
+)
+
+func TestTypeIndex(t *testing.T) {
+	AddToAnnotation(annotation.GetAnnotation())
+	pkg := "example.com/pkg/apis/ship/v1"
+	// annotations separated from the type by a blank line are read by isNonNamespaced only
+	frigate := &types.Type{Name: types.Name{Package: pkg, Name: "Frigate"}, Kind: types.Struct,
+		CommentLines:              []string{"+kubebuilder:resource:path=frigates"},
+		SecondClosestCommentLines: []string{"+genclient:nonNamespaced", "+kubebuilder:subresource:status"},
+	}
+	spec := &types.Type{Name: types.Name{Package: pkg, Name: "FrigateSpec"}, Kind: types.Struct,
+		SecondClosestCommentLines: []string{"+kubebuilder:resource:path=specs"},
+	}
+
+	x := newTypeIndex(types.Universe{}, []*types.Type{frigate, spec})
+	if !x.isAPIResource(frigate) || x.isAPIResource(spec) {
+		t.Errorf("expect API resource declared by closest comments only")
+	}
+	if !x.isNonNamespaced(frigate) || !IsNonNamespaced(frigate) {
+		t.Errorf("expect nonNamespaced read from second closest comments")
+	}
+	if x.hasStatusSubresource(frigate) {
+		t.Errorf("expect subresource of second closest comments not indexed")
+	}
+
+	// indexes of loads are independent
+	if y := newTypeIndex(types.Universe{}, nil); y.HasTarget(frigate.Name.String()) {
+		t.Errorf("expect new index without types of other loads")
+	}
+}
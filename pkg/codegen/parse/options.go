@@ -0,0 +1,109 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parse
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"k8s.io/gengo/args"
+	"k8s.io/gengo/parser"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/config"
+)
+
+// Options contains the parser options, and options of loading API resources of the APIs directory, e.g. for
+// explaining and generating CRDs, see Load.
+type Options struct {
+	SkipMapValidation bool
+
+	// SkipRBACValidation flag determines whether to check RBAC annotations
+	// for the controller or not at parse stage.
+	SkipRBACValidation bool
+
+	// APIsDir is the directory of API packages, which are loaded recursively, see Load
+	APIsDir string
+	// Domain is domain of API groups, read from +domain of doc.go of the apis package if empty
+	Domain string
+	// Features are feature flags enabled for annotations conditional on them, see annotation.ConditionKey
+	Features []string
+	// Strict makes annotation of unregistered module under registered header an error
+	Strict bool
+
+	// project is the project file applied to default annotation on loading
+	project *config.Config
+}
+
+// SetDefaults sets up the default options for loading API resources.
+func (o *Options) SetDefaults() {
+	o.APIsDir = filepath.Join(".", "pkg", "apis")
+	o.Strict = true
+}
+
+// ApplyConfig overrides defaults by options of "crd" generator, strictness and features in project file.
+// Input directory of the generator is the APIs directory, and option "domain" is domain of API groups.
+func (o *Options) ApplyConfig(c *config.Config) error {
+	o.project = c
+	g := c.Generator("crd")
+	if err := g.CheckOptions("crd", "domain"); err != nil {
+		return err
+	}
+	if len(g.InputDir) > 0 {
+		o.APIsDir = g.InputDir
+	}
+	if domain, ok := g.Option("domain"); ok {
+		o.Domain = domain
+	}
+	if c.Strict != nil {
+		o.Strict = *c.Strict
+	}
+	if len(c.Features) > 0 {
+		o.Features = c.Features
+	}
+	return nil
+}
+
+// Load loads Go packages under the APIs directory and parses their API resources by default annotation, with
+// the project file, strictness and features applied.
+func (o *Options) Load() (*APIs, error) {
+	ann := annotation.GetAnnotation()
+	if o.project != nil {
+		o.project.Apply(ann)
+	}
+	ann.Strict(o.Strict)
+	ann.Features(o.Features...)
+
+	b := parser.New()
+	if err := b.AddDirRecursive(o.APIsDir); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %v", o.APIsDir, err)
+	}
+	ctx, err := NewContext(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load types of %s: %v", o.APIsDir, err)
+	}
+	// apis package is the root of the loaded packages, whose doc.go declares the domain
+	apisPkg := ""
+	for _, p := range b.FindPackages() {
+		if len(apisPkg) == 0 || len(p) < len(apisPkg) {
+			apisPkg = p
+		}
+	}
+	arguments := args.Default()
+	arguments.CustomArgs = o
+	return NewAPIs(ctx, arguments, o.Domain, apisPkg), nil
+}
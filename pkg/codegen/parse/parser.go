@@ -22,8 +22,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"go/build"
+	"go/token"
 	"log"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -32,6 +32,7 @@ import (
 
 	"github.com/fanzhangio/go-annotation/pkg/annotation"
 	"github.com/fanzhangio/go-annotation/pkg/codegen"
+	"github.com/fanzhangio/go-annotation/pkg/resource"
 	"github.com/markbates/inflect"
 	"github.com/pkg/errors"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -64,6 +65,27 @@ type APIs struct {
 	Groups                map[string]types.Package
 	Rules                 []rbacv1.PolicyRule
 	Informers             map[v1.GroupVersionKind]bool
+
+	// Index holds annotations of all types in the load, keyed by full type name
+	Index *annotation.Index
+
+	// types indexes annotations of types with defaults of their package docs, Index is its index
+	types *typeIndex
+
+	// results holds results of modules registered by addToAnnotation
+	results moduleResults
+}
+
+// moduleResults are typed results (Meta) of API resource modules. They are set when modules are registered,
+// so results are read without asserting Meta of modules looked up by name.
+type moduleResults struct {
+	resource      *resource.Resources
+	nonNamespaced *bool
+	categories    *[]string
+	subresource   *bool
+	scale         **v1beta1.CustomResourceSubresourceScale
+	printColumns  *[]v1beta1.CustomResourceColumnDefinition
+	schema        *schema
 }
 
 // NewAPIs returns a new APIs instance with given context.
@@ -75,12 +97,13 @@ func NewAPIs(context *generator.Context, arguments *args.GeneratorArgs, domain,
 		APIsPkg:   apisPkg,
 	}
 
-	b.parseAPIResource()
-	b.parseGroupNames()
-	b.parseAPIs()
+	// domain is parsed first, since resources are grouped by full group names
 	if len(b.Domain) == 0 {
 		b.parseDomain()
 	}
+	b.parseAPIResource()
+	b.parseGroupNames()
+	b.parseAPIs()
 	return b
 }
 
@@ -94,51 +117,68 @@ func (b *APIs) parseAPIResource() {
 	b.ByGroupKindVersion = map[string]map[string]map[string]*codegen.APIResource{}
 	b.SubByGroupVersionKind = map[string]map[string]map[string]*types.Type{}
 
-	ann := annotation.GetAnnotation()
+	// register api annoations once, modules reset their state on entering each type. Modules are registered
+	// before types are indexed, since defaults of package docs are inherited by modules.
+	ann := b.addToAnnotation(annotation.GetAnnotation())
+	b.types = newTypeIndex(b.context.Universe, b.context.Order)
+	b.Index = b.types.Index
+	if err := ann.StartRun(); err != nil {
+		log.Fatalf("failed to start parsing api annotations: %v", err)
+	}
+	// macros defined in package docs are collected before types are parsed, so types may use macros of packages
+	// parsed after them
+	defined := map[string]bool{}
 	for _, t := range b.context.Order {
-		// register api annoations
-
-		r := &codegen.APIResource{}
-		b.parseSubresourceRequest(t, ann)
-		b.parseResources(r, ann)
-		b.parseSubresource(ann)
-		b.parseNamespace(ann)
-		b.parseCategories(ann)
-		b.parsePrintColumn(t, ann)
-
-		if IsAPIResource(t) {
+		if pkg := t.Name.Package; !defined[pkg] {
+			defined[pkg] = true
+			if err := b.parseDefinitions(b.context.Universe[pkg], ann); err != nil {
+				log.Fatalf("failed to parse macros of package %s: %v", pkg, err)
+			}
+		}
+	}
+	pkg := ""
+	for _, t := range b.context.Order {
+		if b.types.isAPIResource(t) {
+			r := &codegen.APIResource{}
 
 			// parse packages
 			versioned := t.Name.Package
 			b.VersionedPkgs.Insert(versioned)
 			unversioned := filepath.Dir(versioned)
 			b.UnversionedPkgs.Insert(unversioned)
+			if versioned != pkg {
+				pkg = versioned
+				if err := ann.EnterPackage(pkg); err != nil {
+					log.Fatalf("failed to enter package %s: %v", pkg, err)
+				}
+			}
 
 			// parse APIResource by annotations
-			parseAPIAnnotation(t, ann)
-
-			// parse APIResource
-			res, ok := ann.GetModule("resource").Meta.(*codegen.APIResource)
-			if !ok {
-				log.Fatalf("api resource module not set meta correctly")
+			target := annotation.Target{Package: t.Name.Package, Name: t.Name.Name, Object: t}
+			if err := ann.EnterType(target); err != nil {
+				log.Fatalf("failed to enter type %s: %v", t.Name, err)
+			}
+			if err := b.parseAPIAnnotation(t, ann); err != nil {
+				log.Fatalf("failed to parse annotations of %s: %v", t.Name, err)
 			}
-			nonNamespaced, ok := ann.GetModule("nonNamespaced").Meta.(*bool)
-			if !ok {
-				log.Fatalf("nonNamespaced module not set meta correctly")
+			if err := ann.LeaveType(target); err != nil {
+				log.Fatalf("failed to leave type %s: %v", t.Name, err)
 			}
 
-			r.NonNamespaced = *nonNamespaced
+			// parse APIResource
+			res := b.results.resource
+			r.NonNamespaced = *b.results.nonNamespaced
 			r.Group = GetGroup(t)
 			r.Version = GetVersion(t, r.Group)
 			r.Kind = GetKind(t, r.Group)
 			r.Domain = b.Domain
 
-			if res.Resource != "" {
-				r.Resource = res.Resource
+			if res.Current.Resource != "" {
+				r.Resource = res.Current.Resource
 			} else {
 				r.Resource = strings.ToLower(inflect.Pluralize(r.Kind))
 			}
-			r.ShortName = res.ShortName
+			r.ShortName = res.Current.ShortName
 
 			// Copy the Status strategy to mirror the non-status strategy
 			r.StatusStrategy = strings.TrimSuffix(r.Strategy, "Strategy")
@@ -178,19 +218,11 @@ func (b *APIs) parseAPIResource() {
 			r.CRD.Status.StoredVersions = []string{}
 
 			// parse Categories
-			categories, ok := ann.GetModule("categories").Meta.(*[]string)
-			if !ok {
-				log.Fatalf("categories module not set meta correctly")
-			}
-			r.CRD.Spec.Names.Categories = *categories
-			r.Categories = *categories
+			r.CRD.Spec.Names.Categories = *b.results.categories
+			r.Categories = *b.results.categories
 
 			// parse subresource:status
-			substatus, ok := ann.GetModule("subresource").Meta.(*bool)
-			if !ok {
-				log.Fatalf("subresource:status module not set meta correctly")
-			}
-			if *substatus {
+			if *b.results.subresource {
 				if r.CRD.Spec.Subresources == nil {
 					r.CRD.Spec.Subresources = &v1beta1.CustomResourceSubresources{}
 				}
@@ -198,28 +230,19 @@ func (b *APIs) parseAPIResource() {
 			}
 
 			// parse subresource:scale
-			scale, ok := ann.GetModule("subresource").SubModules["scale"].Meta.(*v1beta1.CustomResourceSubresourceScale)
-			if !ok {
-				log.Fatalf("subresource:scale module not set meta correctly")
-			}
-			if scale != nil {
+			if scale := b.results.scale; *scale != nil {
 				if r.CRD.Spec.Subresources == nil {
-					fmt.Printf("[Debug] ========== parseSubresource() -> Initial r.CRD.Spec.Subresources\n")
+					annotation.Log().V(3).Info("initializing subresources of CRD", "type", t.Name.String(), "subresource", "scale")
 					r.CRD.Spec.Subresources = &v1beta1.CustomResourceSubresources{}
 				}
-				r.CRD.Spec.Subresources.Scale = scale
+				r.CRD.Spec.Subresources.Scale = *scale
 			}
 
 			// parse AdditionalPrintColumn
-			result, ok := ann.GetModule("printcolumn").Meta.(*[]v1beta1.CustomResourceColumnDefinition)
-			if !ok {
-				log.Fatalf("printcolumn module not set meta correctly")
-			}
-			r.CRD.Spec.AdditionalPrinterColumns = *result
+			r.CRD.Spec.AdditionalPrinterColumns = *b.results.printColumns
 
 			// parse JSONSchemaProps and Validation
-			r.JSONSchemaProps, r.Validation = b.typeToJSONSchemaProps(t, sets.NewString(), []string{}, true)
-			r.JSONSchemaProps.Type = ""
+			r.JSONSchemaProps, r.Validation = b.results.schema.Props, b.results.schema.Validation
 			j, err := json.MarshalIndent(r.JSONSchemaProps, "", "    ")
 			if err != nil {
 				log.Fatalf("Could not Marshall validation %v\n", err)
@@ -243,56 +266,120 @@ func (b *APIs) parseAPIResource() {
 			b.ByGroupKindVersion[r.Group][r.Kind][r.Version] = r
 			b.ByGroupVersionKind[r.Group][r.Version][r.Kind] = r
 			r.Type = t
+			annotation.Log().V(1).Info("parsed API resource", "type", t.Name.String(), "group", r.Group, "version", r.Version, "kind", r.Kind)
 		}
 	}
-
+	if err := ann.Finish(); err != nil {
+		log.Fatalf("failed to finish parsing api annotations: %v", err)
+	}
 }
 
-// parseAPI annotation
-func parseAPIAnnotation(t *types.Type, ann annotation.Annotation) error {
-	for _, c := range t.CommentLines {
-		if err := ann.Parse(c); err != nil {
+// parseAPI annotation, handlers of modules are invoked in dependency order.
+// Comments of the type are parsed at their positions in source, annotations of overlays follow them and are parsed
+// at their positions in overlay files.
+// Defaults inherited from package doc precede them, see typeIndex.inherited.
+func (b *APIs) parseAPIAnnotation(t *types.Type, ann annotation.Annotation) error {
+	comments := []string{}
+	positions := map[string][]token.Position{}
+	// comments are expanded line by line, so lines adapted or expanded keep positions of their source lines
+	for n, pos := range b.types.commentPositions(b.context.Universe, t) {
+		for _, c := range b.types.consts.expand(t.Name.Package, t.CommentLines[n:n+1]) {
+			comments = append(comments, c)
+			positions[c] = append(positions[c], pos)
+		}
+	}
+	for _, l := range ann.OverlayLines(t.Name.Package, t.Name.Name) {
+		for _, c := range b.types.consts.expand(t.Name.Package, []string{l.Text}) {
+			comments = append(comments, c)
+			positions[c] = append(positions[c], l.Position)
+		}
+	}
+	defaults := b.types.inherited(t, comments)
+	for _, c := range defaults {
+		positions[c] = append([]token.Position{{}}, positions[c]...)
+	}
+	comments = append(defaults, comments...)
+	// ordering is stable, so positions of the same comment are taken in order
+	comments, err := annotation.Ordered(ann, comments)
+	if err != nil {
+		return err
+	}
+	for _, c := range comments {
+		pos := positions[c][0]
+		positions[c] = positions[c][1:]
+		if err := ann.ParseAt(c, pos); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (b *APIs) parseResources(r *codegen.APIResource, a annotation.Annotation) annotation.Annotation {
-	a.Module(&annotation.Module{
-		Name: "resource",
-		Meta: r,
-		Do: func(commentText string) error {
-			// fmt.Printf("\n[Debug]] ... parseResourceAnnotation() with comment (%s)\n", commentText)
-			// indexes all types with the comment "// +resource=RESOURCE" by GroupVersionKind and GroupKindVersion
-			for _, elem := range strings.Split(commentText, ",") {
-				key, value, err := annotation.ParseKV(elem)
-				if err != nil {
-					return fmt.Errorf("// +kubebuilder:resource: tags must be key value pairs.  Expected "+
-						"keys [path=<resourcepath>] "+
-						"Got string: [%s]", commentText)
-				}
-				switch key {
-				case "path":
-					r.Resource = value
-				case "shortName":
-					r.ShortName = value
-				default:
-					return fmt.Errorf("The given input %s is invalid", value)
-				}
+// parseDefinitions registers macros defined in package doc, e.g. doc.go, for types of the run
+func (b *APIs) parseDefinitions(p *types.Package, ann annotation.Annotation) error {
+	if p == nil {
+		return nil
+	}
+	positions := b.types.docPositions(p)
+	for n, c := range p.DocComments {
+		if i := ann.Resolve(c); i != nil && len(i.Header) > 0 && i.Module == annotation.DefineModule {
+			if err := ann.ParseAt(c, positions[n]); err != nil {
+				return err
 			}
-			return nil
-		},
-	})
+		}
+	}
+	return nil
+}
+
+// AddToAnnotation registers API resource and CRD modules into given annotation, e.g. for tools listing or
+// validating annotations. Subresource requests are not recorded since no types are loaded.
+func AddToAnnotation(a annotation.Annotation) annotation.Annotation {
+	b := &APIs{SubByGroupVersionKind: map[string]map[string]map[string]*types.Type{}}
+	return b.addToAnnotation(a)
+}
+
+// addToAnnotation registers modules of API resource annotations
+func (b *APIs) addToAnnotation(a annotation.Annotation) annotation.Annotation {
+	b.parseSubresourceRequest(a)
+	b.parseResources(a)
+	b.parseValidation(a)
+	b.parseSubresource(a)
+	b.parseNamespace(a)
+	b.parseCategories(a)
+	b.parsePrintColumn(a)
+	b.parseField(a)
 	return a
 }
 
+// parseResources registers resource module, its result is resource of the current type and all resources of the run.
+// Resources are grouped by full group names, i.e. GetGroup of their types in the domain, or by package docs for
+// declarations parsed without types loaded.
+func (b *APIs) parseResources(a annotation.Annotation) annotation.Annotation {
+	b.results.resource = resource.NewResources(func(t annotation.Target) string {
+		if obj, ok := t.Object.(*types.Type); ok {
+			return resource.FullGroup(GetGroup(obj), b.Domain)
+		}
+		return resource.DocGroup(t.Package)
+	})
+	return b.results.resource.AddToAnnotation(a)
+}
+
 // subresourceRequest module is for compatibility
-func (b *APIs) parseSubresourceRequest(t *types.Type, a annotation.Annotation) annotation.Annotation {
+func (b *APIs) parseSubresourceRequest(a annotation.Annotation) annotation.Annotation {
+	var t *types.Type
 	a.Module(&annotation.Module{
 		Name: "subresource-request",
+		Doc:  "type is a subresource request, e.g. +subresource-request",
+		Hooks: annotation.Hooks{
+			OnEnterType: func(target annotation.Target) error {
+				t, _ = target.Object.(*types.Type)
+				return nil
+			},
+		},
 		Do: func(commentText string) error {
 			//fmt.Printf("\n[Debug]] ... parse parseSubresourceRequest() with comment (%s)\n", commentText)
+			if t == nil {
+				return nil
+			}
 			group := GetGroup(t)
 			version := GetVersion(t, group)
 			kind := GetKind(t, group)
@@ -314,18 +401,55 @@ func (b *APIs) parseSubresourceRequest(t *types.Type, a annotation.Annotation) a
 //      `+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=`
 func (b *APIs) parseSubresource(a annotation.Annotation) annotation.Annotation {
 	var found bool
-	var scale v1beta1.CustomResourceSubresourceScale
+	var scale *v1beta1.CustomResourceSubresourceScale
+	b.results.subresource, b.results.scale = &found, &scale
 	a.Module(&annotation.Module{
-		Name: "subresource",
-		Meta: &found,
+		Name:     "subresource",
+		Meta:     &found,
+		Doc:      "subresources of the CRD, e.g. +kubebuilder:subresource:status",
+		Requires: []string{"validation"},
+		Hooks: annotation.Hooks{
+			OnEnterType: func(annotation.Target) error {
+				found, scale = false, nil
+				return nil
+			},
+			// scale paths are checked against the schema of the type
+			OnLeaveType: func(t annotation.Target) error {
+				if scale == nil {
+					return nil
+				}
+				s := b.results.schema
+				if s == nil {
+					return fmt.Errorf("validation module is not registered with subresource module")
+				}
+				paths := map[string]string{specReplicasPath: scale.SpecReplicasPath, statusReplicasPath: scale.StatusReplicasPath}
+				if scale.LabelSelectorPath != nil {
+					paths[labelSelectorPath] = *scale.LabelSelectorPath
+				}
+				for _, key := range []string{specReplicasPath, statusReplicasPath, labelSelectorPath} {
+					if path, ok := paths[key]; ok && !hasJSONPath(s.Props, path) {
+						return fmt.Errorf("invalid scale %s %s of %s, it is not found in the schema", key, path, t.Name)
+					}
+				}
+				return nil
+			},
+		},
 		Do: func(string) error {
 			found = true
 			return nil
 		},
 		SubModules: map[string]*annotation.Module{
 			"scale": &annotation.Module{
-				Name: "scale",
-				Meta: &scale,
+				Name:        "scale",
+				Meta:        &scale,
+				Doc:         "scale subresource of the CRD",
+				Cardinality: annotation.OncePerTarget,
+				Merge:       annotation.MergeKeys,
+				Params: []annotation.Param{
+					{Name: specReplicasPath, Doc: "JSONPath of the desired replicas in spec", Required: true},
+					{Name: statusReplicasPath, Doc: "JSONPath of the observed replicas in status", Required: true},
+					{Name: labelSelectorPath, Doc: "JSONPath of the label selector in status"},
+				},
 				Do: func(commentText string) error {
 					jsonPath := map[string]string{}
 					for _, elem := range strings.Split(commentText, ",") {
@@ -348,6 +472,7 @@ func (b *APIs) parseSubresource(a annotation.Annotation) annotation.Annotation {
 					if !ok {
 						return fmt.Errorf(jsonPathError)
 					}
+					scale = &v1beta1.CustomResourceSubresourceScale{}
 					scale.SpecReplicasPath = jsonPath[specReplicasPath]
 					scale.StatusReplicasPath = jsonPath[statusReplicasPath]
 
@@ -363,12 +488,72 @@ func (b *APIs) parseSubresource(a annotation.Annotation) annotation.Annotation {
 	return a
 }
 
+// schema is the result of validation module, OpenAPI schema of the current type generated from its validation annotations
+type schema struct {
+	Props      v1beta1.JSONSchemaProps
+	Validation string
+}
+
+// parseValidation registers validation module, which generates schema of the type on entering it.
+// Validation annotations are handled by typeToJSONSchemaProps, so the handler does nothing.
+func (b *APIs) parseValidation(a annotation.Annotation) annotation.Annotation {
+	s := &schema{}
+	b.results.schema = s
+	a.Module(&annotation.Module{
+		Name: "validation",
+		Meta: s,
+		Doc:  "OpenAPI validation of the type or field, e.g. +kubebuilder:validation:Maximum=10",
+		Hooks: annotation.Hooks{
+			OnEnterType: func(target annotation.Target) error {
+				*s = schema{}
+				t, ok := target.Object.(*types.Type)
+				if !ok || t == nil {
+					return nil
+				}
+				s.Props, s.Validation = b.typeToJSONSchemaProps(t, sets.NewString(), []string{}, true)
+				s.Props.Type = ""
+				return nil
+			},
+		},
+		Do: func(string) error {
+			return nil
+		},
+	})
+	return a
+}
+
+// hasJSONPath returns true if given JSONPath, e.g. ".spec.replicas", is found in properties of schema.
+// Path nested in object without properties, e.g. map, is taken as found.
+func hasJSONPath(props v1beta1.JSONSchemaProps, path string) bool {
+	for _, p := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if len(props.Properties) == 0 {
+			return true
+		}
+		next, ok := props.Properties[p]
+		if !ok {
+			return false
+		}
+		props = next
+	}
+	return true
+}
+
 // parseCategories validates annotation e.g. "+kubebuilder:categories:foo,bar,hoo""
 func (b *APIs) parseCategories(a annotation.Annotation) annotation.Annotation {
 	var categories []string
+	b.results.categories = &categories
 	a.Module(&annotation.Module{
-		Name: "categories",
-		Meta: &categories,
+		Name:    "categories",
+		Meta:    &categories,
+		Doc:     "categories of the CRD split by comma, e.g. +kubebuilder:categories:foo,bar",
+		Merge:   annotation.MergeUnion,
+		Inherit: annotation.InheritAppend,
+		Hooks: annotation.Hooks{
+			OnEnterType: func(annotation.Target) error {
+				categories = nil
+				return nil
+			},
+		},
 		Do: func(commentText string) error {
 			for _, elem := range strings.Split(commentText, ",") {
 				categories = append(categories, elem)
@@ -381,11 +566,28 @@ func (b *APIs) parseCategories(a annotation.Annotation) annotation.Annotation {
 
 // printcolumn requires name,type,JSONPath fields and rest of the field are optional
 // +kubebuilder:printcolumn:name=<name>,type=<type>,description=<desc>,JSONPath:<.spec.Name>,priority=<int32>,format=<format>
-func (b *APIs) parsePrintColumn(t *types.Type, a annotation.Annotation) annotation.Annotation {
+func (b *APIs) parsePrintColumn(a annotation.Annotation) annotation.Annotation {
 	result := []v1beta1.CustomResourceColumnDefinition{}
+	b.results.printColumns = &result
 	a.Module(&annotation.Module{
-		Name: "printcolumn",
-		Meta: &result,
+		Name:    "printcolumn",
+		Meta:    &result,
+		Doc:     "additional printer column of the CRD",
+		Inherit: annotation.InheritAppend,
+		Hooks: annotation.Hooks{
+			OnEnterType: func(annotation.Target) error {
+				result = []v1beta1.CustomResourceColumnDefinition{}
+				return nil
+			},
+		},
+		Params: []annotation.Param{
+			{Name: printColumnName, Doc: "name of the column", Required: true},
+			{Name: printColumnType, Doc: "type of the column", Required: true, Values: []string{"integer", "number", "string", "boolean", "date"}},
+			{Name: printColumnPath, Doc: "JSONPath of the column value", Required: true},
+			{Name: printColumnDescr, Doc: "description of the column"},
+			{Name: printColumnFormat, Doc: "format of the column", Values: []string{"int32", "int64", "float", "double", "byte", "date", "date-time", "password"}},
+			{Name: printColumnPri, Doc: "priority of the column"},
+		},
 		Do: func(commentText string) error {
 			config := v1beta1.CustomResourceColumnDefinition{}
 			var count int
@@ -445,13 +647,33 @@ func (b *APIs) parsePrintColumn(t *types.Type, a annotation.Annotation) annotati
 	return a
 }
 
+// parseField registers field module, which omits field of CRD schema by its condition, e.g.
+// `+kubebuilder:field:if=enterprise`. Fields are omitted by getMembers, so the module does nothing on parsing.
+func (b *APIs) parseField(a annotation.Annotation) annotation.Annotation {
+	a.Module(&annotation.Module{
+		Name: "field",
+		Doc:  "Field of CRD schema, e.g. +kubebuilder:field:if=enterprise omits the field unless feature enterprise is enabled",
+		Do:   func(string) error { return nil },
+	})
+	return a
+}
+
 // TODO(fanz): nonNamespaced will be put into submodule of genclient
 // Currently, having "nonNamespaced" as module of Header "genclient"
 func (b *APIs) parseNamespace(a annotation.Annotation) annotation.Annotation {
 	var found bool
+	b.results.nonNamespaced = &found
 	a.Module(&annotation.Module{
-		Name: "nonNamespaced",
-		Meta: &found,
+		Name:        "nonNamespaced",
+		Meta:        &found,
+		Doc:         "resource is cluster scoped, e.g. +genclient:nonNamespaced",
+		Cardinality: annotation.OncePerTarget,
+		Hooks: annotation.Hooks{
+			OnEnterType: func(annotation.Target) error {
+				found = false
+				return nil
+			},
+		},
 		Do: func(commentText string) error {
 			found = true
 			return nil
@@ -497,7 +719,7 @@ func parseDomainFromFiles(paths []string) string {
 			filePath := strings.Join([]string{build.Default.GOPATH, "src", path, "doc.go"}, "/")
 			lines := []string{}
 
-			file, err := os.Open(filePath)
+			file, err := annotation.Fs().Open(filePath)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -938,7 +1160,7 @@ func (b *APIs) getMembers(t *types.Type, found sets.String) (map[string]v1beta1.
 	// Don't allow recursion until we support it through refs
 	// TODO: Support recursion
 	if found.Has(t.Name.String()) {
-		fmt.Printf("Breaking recursion for type %s", t.Name.String())
+		annotation.Log().V(1).Info("breaking recursion of schema", "type", t.Name.String())
 		return members, result, required
 	}
 	found.Insert(t.Name.String())
@@ -950,6 +1172,11 @@ func (b *APIs) getMembers(t *types.Type, found sets.String) (map[string]v1beta1.
 			//fmt.Printf("Skipping member %s %s\n", member.Name, member.Type.Name.String())
 			continue
 		}
+		// Skip fields omitted for enabled features, e.g. by +kubebuilder:field:if=enterprise
+		comments, included := applied(b.types.consts.withOverlay(t.Name.Package, t.Name.Name+"."+member.Name, memberLines(member)))
+		if !included {
+			continue
+		}
 		ts := strings.Split(tags[1], ",")
 		name := member.Name
 		strat := ""
@@ -971,7 +1198,7 @@ func (b *APIs) getMembers(t *types.Type, found sets.String) (map[string]v1beta1.
 			}
 			required = append(required, re...)
 		} else {
-			m, r := b.typeToJSONSchemaProps(member.Type, found, member.CommentLines, false)
+			m, r := b.typeToJSONSchemaProps(member.Type, found, comments, false)
 			members[name] = m
 			result[name] = r
 			if !strings.HasSuffix(strat, "omitempty") {
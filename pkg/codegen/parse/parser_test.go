@@ -0,0 +1,196 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/resource"
+	"github.com/spf13/afero"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/gengo/generator"
+	"k8s.io/gengo/parser"
+)
+
+// newTestContext returns context of types loaded from given sources by package. Every package is a single file,
+// which is named doc.go so that its package doc is read, since packages are type-checked on adding files.
+func newTestContext(t *testing.T, sources map[string]string) *generator.Context {
+	p := parser.New()
+	for pkg, src := range sources {
+		if err := p.AddFileForTest(pkg, pkg+"/doc.go", []byte(src)); err != nil {
+			t.Fatalf("failed to add package %s: %v", pkg, err)
+		}
+	}
+	ctx, err := NewContext(p)
+	if err != nil {
+		t.Fatalf("failed to load types: %v", err)
+	}
+	return ctx
+}
+
+func TestModuleResults(t *testing.T) {
+	pkg := "example.com/pkg/apis/ship/v1"
+	ctx := newTestContext(t, map[string]string{pkg: `package v1
+
+// Frigate is the Schema for the frigates API
+// +kubebuilder:resource:path=frigates,shortName=fg
+// +genclient:nonNamespaced
+// +kubebuilder:categories:ships,all
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name=replicas,type=integer,JSONPath=.spec.replicas
+type Frigate struct {
+	Spec FrigateSpec ` + "`json:\"spec,omitempty\"`" + `
+}
+
+// FrigateSpec defines the desired state of Frigate
+type FrigateSpec struct {
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 ` + "`json:\"replicas\"`" + `
+}
+`})
+	b := &APIs{context: ctx, Domain: "example.com"}
+	b.parseAPIResource()
+
+	// results of the last type parsed are kept by modules
+	if res := b.results.resource.Current; res.Resource != "frigates" || res.ShortName != "fg" {
+		t.Errorf("expect resource frigates of short name fg, got %+v", res)
+	}
+	if byGroup := b.results.resource.ByGroup; !byGroup["ship.example.com"].Has("frigates") {
+		t.Errorf("expect frigates by full group ship.example.com, got %v", byGroup)
+	}
+	if !*b.results.nonNamespaced || !*b.results.subresource || *b.results.scale != nil {
+		t.Errorf("expect nonNamespaced resource of status subresource only")
+	}
+	if exp := []string{"ships", "all"}; !reflect.DeepEqual(*b.results.categories, exp) {
+		t.Errorf("expect categories %v, got %v", exp, *b.results.categories)
+	}
+	columns := []v1beta1.CustomResourceColumnDefinition{{Name: "replicas", Type: "integer", JSONPath: ".spec.replicas"}}
+	if !reflect.DeepEqual(*b.results.printColumns, columns) {
+		t.Errorf("expect print columns %v, got %v", columns, *b.results.printColumns)
+	}
+	if !hasJSONPath(b.results.schema.Props, ".spec.replicas") {
+		t.Errorf("expect schema of Frigate, got %+v", b.results.schema.Props)
+	}
+
+	r := b.ByGroupVersionKind["ship"]["v1"]["Frigate"]
+	if r == nil {
+		t.Fatalf("expect API resource of Frigate, got %v", b.ByGroupVersionKind)
+	}
+	if !r.NonNamespaced || r.CRD.Spec.Scope != "Cluster" || r.Resource != "frigates" ||
+		!reflect.DeepEqual(r.CRD.Spec.Names.ShortNames, []string{"fg"}) ||
+		!reflect.DeepEqual(r.CRD.Spec.Names.Categories, []string{"ships", "all"}) ||
+		r.CRD.Spec.Subresources == nil || r.CRD.Spec.Subresources.Status == nil ||
+		!reflect.DeepEqual(r.CRD.Spec.AdditionalPrinterColumns, columns) {
+		t.Errorf("expect CRD spec of module results, got %+v", r.CRD.Spec)
+	}
+
+	// results read by path are the results kept on registration
+	ann := annotation.GetAnnotation()
+	var categories *[]string
+	if err := annotation.Result(ann, "categories", &categories); err != nil || categories != b.results.categories {
+		t.Errorf("expect categories result kept on registration, got %v (%v)", categories, err)
+	}
+	var s *schema
+	if err := annotation.Result(ann, "validation", &s); err != nil || s != b.results.schema {
+		t.Errorf("expect schema result kept on registration, got %v (%v)", s, err)
+	}
+	var found *bool
+	if err := annotation.Result(ann, "categories", &found); err == nil {
+		t.Errorf("expect error reading categories result as %T", found)
+	}
+	if err := annotation.Result(ann, "shipyard", &found); err == nil || err.Error() != "module shipyard is not registered" {
+		t.Errorf("expect error reading result of unregistered module, got %v", err)
+	}
+
+	// runs parsing API types by resource module alone disable the other modules of API types
+	for _, m := range AddToAnnotation(annotation.Build()).ListModules() {
+		if m.Name != "resource" && !sets.NewString(resource.TypeModules...).Has(m.Name) {
+			t.Errorf("expect module %s of API types in resource.TypeModules", m.Name)
+		}
+	}
+}
+
+func TestInheritedDefaults(t *testing.T) {
+	pkg := "example.com/pkg/apis/ship/v1"
+	ctx := newTestContext(t, map[string]string{pkg: `// Package v1 contains API Schema definitions of ship v1
+// +genclient:nonNamespaced
+// +kubebuilder:categories:ships
+package v1
+
+// Frigate is the Schema for the frigates API
+// +kubebuilder:resource:path=frigates
+type Frigate struct {
+	Replicas int32 ` + "`json:\"replicas\"`" + `
+}
+`})
+	b := &APIs{context: ctx, Domain: "example.com"}
+	b.parseAPIResource()
+	b.parseAPIs()
+
+	r := b.ByGroupVersionKind["ship"]["v1"]["Frigate"]
+	if r == nil {
+		t.Fatalf("expect API resource of Frigate, got %v", b.ByGroupVersionKind)
+	}
+	if !r.NonNamespaced || r.CRD.Spec.Scope != "Cluster" || !reflect.DeepEqual(r.CRD.Spec.Names.Categories, []string{"ships"}) {
+		t.Errorf("expect cluster scoped CRD of categories [ships] inherited from package doc, got %+v", r.CRD.Spec)
+	}
+	for _, s := range b.APIs.Groups["ship"].Structs {
+		if s.Name == "Frigate" && !s.NonNamespaced {
+			t.Errorf("expect indexed Frigate nonNamespaced inherited from package doc")
+		}
+	}
+	if !b.types.has(r.Type, "categories") {
+		t.Errorf("expect indexed Frigate categories inherited from package doc")
+	}
+}
+
+func TestAnnotationPositions(t *testing.T) {
+	annotation.SetFs(afero.NewMemMapFs())
+	defer annotation.SetFs(nil)
+	pkg := "example.com/pkg/apis/ship/v1"
+	src := `package v1
+
+// Frigate is the Schema for the frigates API
+// +kubebuilder:resource:path=frigates
+// +kubebuilder:resource:path=ships
+type Frigate struct{}
+`
+	ctx := newTestContext(t, map[string]string{pkg: src})
+	// source of the package is read from its directory, which packages added for tests don't have
+	ctx.Universe[pkg].SourcePath = "/src/ship/v1"
+	if err := afero.WriteFile(annotation.Fs(), "/src/ship/v1/frigate.go", []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := &APIs{context: ctx, Domain: "example.com"}
+	ann := b.addToAnnotation(annotation.AddDefaults(annotation.Build()))
+	b.types = newTypeIndex(ctx.Universe, ctx.Order)
+	typ := ctx.Universe[pkg].Types["Frigate"]
+	target := annotation.Target{Package: pkg, Name: "Frigate", Object: typ}
+	if err := ann.StartRun(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ann.EnterType(target); err != nil {
+		t.Fatal(err)
+	}
+	exp := `/src/ship/v1/frigate.go:5:1: conflicting value of path for module resource of Frigate: "ships" here and "frigates" at /src/ship/v1/frigate.go:4:1`
+	if err := b.parseAPIAnnotation(typ, ann); err == nil || err.Error() != exp {
+		t.Errorf("expect error %q, got %v", exp, err)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
 	"github.com/pkg/errors"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/gengo/types"
@@ -42,25 +43,23 @@ const (
 	printColumnError   = "invalid printcolumn path. name,type, and JSONPath are required kye-value pairs and rest of the fields are optinal. For example: // +kubebuilder:printcolumn:name=abc,type=string,JSONPath=status"
 )
 
-// Options contains the parser options
-type Options struct {
-	SkipMapValidation bool
-
-	// SkipRBACValidation flag determines whether to check RBAC annotations
-	// for the controller or not at parse stage.
-	SkipRBACValidation bool
-}
-
 // IsAPIResource returns true if either of the two conditions become true:
 // 1. t has a +resource/+kubebuilder:resource comment tag
 // 2. t has TypeMeta and ObjectMeta in its member list.
+// Exported helpers read annotations of t alone, APIs reads them with defaults of package docs by typeIndex.
 func IsAPIResource(t *types.Type) bool {
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+resource") || strings.Contains(c, "+kubebuilder:resource") {
-			return true
-		}
+	return newTypeIndex(nil, nil).isAPIResource(t)
+}
+
+func (x *typeIndex) isAPIResource(t *types.Type) bool {
+	if x.has(t, "resource") {
+		return true
 	}
+	return hasObjectMeta(t)
+}
 
+// hasObjectMeta returns true if t has TypeMeta and ObjectMeta in its member list
+func hasObjectMeta(t *types.Type) bool {
 	typeMetaFound, objMetaFound := false, false
 	for _, m := range t.Members {
 		if m.Name == "TypeMeta" && m.Type.String() == "k8s.io/apimachinery/pkg/apis/meta/v1.TypeMeta" {
@@ -78,143 +77,85 @@ func IsAPIResource(t *types.Type) bool {
 
 // IsNonNamespaced returns true if t has a +nonNamespaced comment tag
 func IsNonNamespaced(t *types.Type) bool {
-	if !IsAPIResource(t) {
+	return newTypeIndex(nil, nil).isNonNamespaced(t)
+}
+
+// isNonNamespaced reads comments separated from t by a blank line as well, e.g. "+genclient:nonNamespaced"
+// above "+genclient" of generated clients
+func (x *typeIndex) isNonNamespaced(t *types.Type) bool {
+	if !x.isAPIResource(t) {
 		return false
 	}
-
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+genclient:nonNamespaced") {
-			return true
-		}
+	if x.has(t, "nonNamespaced") {
+		return true
 	}
-
-	for _, c := range t.SecondClosestCommentLines {
-		if strings.Contains(c, "+genclient:nonNamespaced") {
+	ann := annotation.GetAnnotation()
+	comments, _ := applied(x.consts.expand(t.Name.Package, t.SecondClosestCommentLines))
+	for _, c := range comments {
+		if i := ann.Resolve(c); i != nil && i.Path() == "nonNamespaced" {
 			return true
 		}
 	}
-
 	return false
 }
 
 // IsController returns true if t has a +controller or +kubebuilder:controller tag
 func IsController(t *types.Type) bool {
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+controller") || strings.Contains(c, "+kubebuilder:controller") {
-			return true
-		}
-	}
-	return false
+	return newTypeIndex(nil, nil).has(t, "controller")
 }
 
 // IsRBAC returns true if t has a +rbac or +kubebuilder:rbac tag
 func IsRBAC(t *types.Type) bool {
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+rbac") || strings.Contains(c, "+kubebuilder:rbac") {
-			return true
-		}
-	}
-	return false
+	return newTypeIndex(nil, nil).has(t, "rbac")
 }
 
 // hasPrintColumn returns true if t has a +printcolumn or +kubebuilder:printcolumn annotation.
-func hasPrintColumn(t *types.Type) bool {
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+printcolumn") || strings.Contains(c, "+kubebuilder:printcolumn") {
-			return true
-		}
-	}
-	return false
+func (x *typeIndex) hasPrintColumn(t *types.Type) bool {
+	return x.has(t, "printcolumn")
 }
 
 // IsInformer returns true if t has a +informers or +kubebuilder:informers tag
 func IsInformer(t *types.Type) bool {
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+informers") || strings.Contains(c, "+kubebuilder:informers") {
-			return true
-		}
-	}
-	return false
+	return newTypeIndex(nil, nil).has(t, "informers")
 }
 
 // IsAPISubresource returns true if t has a +subresource-request comment tag
 func IsAPISubresource(t *types.Type) bool {
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+subresource-request") {
-			return true
-		}
-	}
-	return false
+	return newTypeIndex(nil, nil).has(t, "subresource-request")
 }
 
 // HasSubresource returns true if t is an APIResource with one or more Subresources
 func HasSubresource(t *types.Type) bool {
-	if !IsAPIResource(t) {
-		return false
-	}
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "subresource") {
-			return true
-		}
-	}
-	return false
+	x := newTypeIndex(nil, nil)
+	return x.isAPIResource(t) && x.has(t, "subresource")
 }
 
 // hasStatusSubresource returns true if t is an APIResource annotated with
 // +kubebuilder:subresource:status
-func hasStatusSubresource(t *types.Type) bool {
-	if !IsAPIResource(t) {
-		return false
-	}
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+kubebuilder:subresource:status") {
-			return true
-		}
-	}
-	return false
+func (x *typeIndex) hasStatusSubresource(t *types.Type) bool {
+	return x.isAPIResource(t) && x.hasElement(t, "subresource", "status")
 }
 
 // hasScaleSubresource returns true if t is an APIResource annotated with
 // +kubebuilder:subresource:scale
-func hasScaleSubresource(t *types.Type) bool {
-	if !IsAPIResource(t) {
-		return false
-	}
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+kubebuilder:subresource:scale") {
-			return true
-		}
-	}
-	return false
+func (x *typeIndex) hasScaleSubresource(t *types.Type) bool {
+	return x.isAPIResource(t) && x.has(t, "subresource:scale")
 }
 
 // hasCategories returns true if t is an APIResource annotated with
 // +kubebuilder:categories
-func hasCategories(t *types.Type) bool {
-	if !IsAPIResource(t) {
-		return false
-	}
-
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+kubebuilder:categories") {
-			return true
-		}
-	}
-	return false
+func (x *typeIndex) hasCategories(t *types.Type) bool {
+	return x.isAPIResource(t) && x.has(t, "categories")
 }
 
 // HasDocAnnotation returns true if t is an APIResource with doc annotation
 // +kubebuilder:doc
 func HasDocAnnotation(t *types.Type) bool {
-	if !IsAPIResource(t) {
-		return false
-	}
-	for _, c := range t.CommentLines {
-		if strings.Contains(c, "+kubebuilder:doc") {
-			return true
-		}
-	}
-	return false
+	return newTypeIndex(nil, nil).hasDocAnnotation(t)
+}
+
+func (x *typeIndex) hasDocAnnotation(t *types.Type) bool {
+	return x.isAPIResource(t) && x.has(t, "doc")
 }
 
 // IsUnversioned returns true if t is in given group, and not in versioned path.
@@ -243,10 +184,15 @@ func GetGroup(t *types.Type) string {
 
 // GetGroupPackage returns group package of t.
 func GetGroupPackage(t *types.Type) string {
-	if IsApisDir(filepath.Base(filepath.Dir(t.Name.Package))) {
-		return t.Name.Package
+	return groupPackage(t.Name.Package)
+}
+
+// groupPackage returns group package of given package, which is either unversioned or versioned package.
+func groupPackage(pkg string) string {
+	if IsApisDir(filepath.Base(filepath.Dir(pkg))) {
+		return pkg
 	}
-	return filepath.Dir(t.Name.Package)
+	return filepath.Dir(pkg)
 }
 
 // GetKind returns kind of t.
@@ -0,0 +1,256 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config loads the project file of go-annotation, which declares headers, enabled modules,
+// strictness and options of generators.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// DefaultFile is the name of the project file looked up in the working directory
+const DefaultFile = "go-annotation.yaml"
+
+// Config is the project file, e.g.
+//
+//	headers: [mycompany]
+//	modules:
+//	  categories: false
+//	strict: false
+//	overlays: [./hack/overlay.yaml]
+//	features: [enterprise]
+//	sources:
+//	  tag: annotation
+//	  yaml: true
+//	adapters: [controller-gen]
+//	macros:
+//	  leader-election:
+//	  - +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=$(verbs)
+//	generators:
+//	  rbac:
+//	    inputDir: ./pkg/controller
+//	    outputDir: ./config/rbac
+//	    options:
+//	      name: manager
+type Config struct {
+	// Headers are registered in addition to the default headers, e.g. "kubebuilder"
+	Headers []string `json:"headers,omitempty"`
+	// Modules enables or disables modules by name, modules are enabled unless set false
+	Modules map[string]bool `json:"modules,omitempty"`
+	// Strict makes annotation of unregistered module under registered header an error, it is true if not set
+	Strict *bool `json:"strict,omitempty"`
+	// Overlays are paths of overlay files relative to the project file, see annotation.Overlay
+	Overlays []string `json:"overlays,omitempty"`
+	// Features are feature flags enabled for conditional annotations, see annotation.ConditionKey
+	Features []string `json:"features,omitempty"`
+	// Macros are annotations of macros by name, see annotation.Macro
+	Macros map[string][]string `json:"macros,omitempty"`
+	// Sources enables sources of annotations other than Go comments, see annotation.Source
+	Sources Sources `json:"sources,omitempty"`
+	// Adapters are names of input adapters rewriting annotations of other tools, e.g. "controller-gen", see
+	// annotation.ControllerGenAdapter
+	Adapters []string `json:"adapters,omitempty"`
+	// Generators are options of generators by name, i.e. "rbac", "webhook" and "crd"
+	Generators map[string]Generator `json:"generators,omitempty"`
+
+	overlays []*annotation.Overlay
+	macros   []*annotation.Macro
+}
+
+// adapters are input adapters by name, see annotation.Adapter
+var adapters = map[string]annotation.Adapter{
+	"controller-gen": annotation.ControllerGenAdapter{},
+}
+
+// Sources enables sources of annotations other than Go comments
+type Sources struct {
+	// Tag is the struct tag key annotations of fields are read from, e.g. "annotation", see annotation.TagSource
+	Tag string `json:"tag,omitempty"`
+	// YAML reads annotations from comments of YAML files, see annotation.YAMLSource
+	YAML bool `json:"yaml,omitempty"`
+}
+
+// Generator is options of single generator. Empty options keep defaults of the generator
+type Generator struct {
+	// InputDir is the directory of Go files to parse annotations from
+	InputDir string `json:"inputDir,omitempty"`
+	// OutputDir is the directory generated files are written to
+	OutputDir string `json:"outputDir,omitempty"`
+	// Options are options specific to the generator, e.g. "name" of rbac and "patchOutputDir" of webhook
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Load reads project file of given path and the overlay files it refers to.
+// Unknown fields are errors, so typos are not ignored silently.
+func Load(path string) (*Config, error) {
+	b, err := afero.ReadFile(annotation.Fs(), path)
+	if err != nil {
+		return nil, err
+	}
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project file %s: %v", path, err)
+	}
+	c := &Config{}
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.DisallowUnknownFields()
+	if err := d.Decode(c); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid project file %s: %v", path, err)
+	}
+	for _, name := range c.Adapters {
+		if _, ok := adapters[name]; !ok {
+			return nil, fmt.Errorf("invalid project file %s: unknown adapter %q", path, name)
+		}
+	}
+	for _, o := range c.Overlays {
+		if !filepath.IsAbs(o) {
+			o = filepath.Join(filepath.Dir(path), o)
+		}
+		overlay, err := annotation.LoadOverlay(o)
+		if err != nil {
+			return nil, err
+		}
+		c.overlays = append(c.overlays, overlay)
+	}
+	names := []string{}
+	for name := range c.Macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.macros = append(c.macros, &annotation.Macro{Name: name, Lines: macroLines(path, b, name, c.Macros[name])})
+	}
+	return c, nil
+}
+
+// LoadDefault reads DefaultFile in the working directory, it returns empty config if the file does not exist.
+func LoadDefault() (*Config, error) {
+	if _, err := annotation.Fs().Stat(DefaultFile); os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	return Load(DefaultFile)
+}
+
+// Apply registers headers, overlays, macros, sources and adapters, disables modules, and sets strictness and features of annotation. It should be applied before
+// modules are registered by generators, since disabled modules are skipped on registration.
+func (c *Config) Apply(a annotation.Annotation) annotation.Annotation {
+	for _, h := range c.Headers {
+		a.Header(h)
+	}
+	for name, enabled := range c.Modules {
+		if !enabled {
+			a.Disable(name)
+		}
+	}
+	if c.Strict != nil {
+		a.Strict(*c.Strict)
+	}
+	if len(c.Features) > 0 {
+		a.Features(c.Features...)
+	}
+	for _, o := range c.overlays {
+		a.Overlay(o)
+	}
+	for _, m := range c.macros {
+		a.Define(m)
+	}
+	if len(c.Sources.Tag) > 0 {
+		a.Source(&annotation.TagSource{Key: c.Sources.Tag})
+	}
+	if c.Sources.YAML {
+		a.Source(annotation.YAMLSource{})
+	}
+	for _, name := range c.Adapters {
+		if adapter, ok := adapters[name]; ok {
+			a.Adapt(adapter)
+		}
+	}
+	return a
+}
+
+// Generator returns options of generator by name, empty options are returned if it is not configured.
+func (c *Config) Generator(name string) Generator {
+	return c.Generators[name]
+}
+
+// Option returns generator specific option by key
+func (g Generator) Option(key string) (string, bool) {
+	v, ok := g.Options[key]
+	return v, ok
+}
+
+// CheckOptions returns error if any generator specific option is not one of given keys
+func (g Generator) CheckOptions(name string, keys ...string) error {
+	for key := range g.Options {
+		found := false
+		for _, k := range keys {
+			if k == key {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown option %q of generator %s, expect one of %v", key, name, keys)
+		}
+	}
+	return nil
+}
+
+// macroLines returns annotations of macro with their positions in project file, which are found by searching
+// for the annotations after the key of the macro under "macros"
+func macroLines(path string, content []byte, name string, texts []string) []annotation.Line {
+	src := strings.Split(string(content), "\n")
+	from := 0
+	for n, l := range src {
+		if strings.HasPrefix(l, "macros:") {
+			from = n
+			break
+		}
+	}
+	for n := from; n < len(src); n++ {
+		key := strings.TrimSpace(src[n])
+		if strings.HasPrefix(key, name+":") || strings.HasPrefix(key, `"`+name+`":`) {
+			from = n
+			break
+		}
+	}
+	lines := []annotation.Line{}
+	for _, text := range texts {
+		pos := token.Position{Filename: path}
+		for n := from; n < len(src); n++ {
+			if col := strings.Index(src[n], text); col >= 0 {
+				pos.Line, pos.Column = n+1, col+1
+				from = n
+				break
+			}
+		}
+		lines = append(lines, annotation.Line{Text: text, Position: pos})
+	}
+	return lines
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		content string
+		exp     *Config
+		err     string
+	}{
+		{
+			content: `
+headers: [mycompany]
+modules:
+  categories: false
+strict: false
+generators:
+  rbac:
+    inputDir: ./pkg/controller
+    options:
+      name: manager
+`,
+			exp: &Config{
+				Headers: []string{"mycompany"},
+				Modules: map[string]bool{"categories": false},
+				Strict:  new(bool),
+				Generators: map[string]Generator{
+					"rbac": {InputDir: "./pkg/controller", Options: map[string]string{"name": "manager"}},
+				},
+			},
+		},
+		{
+			content: ``,
+			exp:     &Config{},
+		},
+		{
+			content: `
+generators:
+  rbac:
+    input-dir: ./pkg
+`,
+			err: `unknown field "input-dir"`,
+		},
+		{
+			content: `adapters: [kubebuilder]`,
+			err:     `unknown adapter "kubebuilder"`,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, DefaultFile)
+	for _, test := range tests {
+		if err := ioutil.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := Load(path)
+		if len(test.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expect error containing %q, got %v", test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+			continue
+		}
+		if !reflect.DeepEqual(c, test.exp) {
+			t.Errorf("expect %+v, got %+v", test.exp, c)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	strict := false
+	c := &Config{
+		Headers:  []string{"mycompany"},
+		Modules:  map[string]bool{"categories": false, "resource": true},
+		Strict:   &strict,
+		Features: []string{"enterprise"},
+		Sources:  Sources{Tag: "kb", YAML: true},
+		Adapters: []string{"controller-gen"},
+	}
+	a := c.Apply(annotation.Build())
+	done := []string{}
+	for _, name := range []string{"categories", "resource"} {
+		name := name
+		a.Module(&annotation.Module{Name: name, Do: func(string) error {
+			done = append(done, name)
+			return nil
+		}})
+	}
+
+	if !reflect.DeepEqual(a.ListHeaders(), []string{"mycompany"}) {
+		t.Errorf("expect headers [mycompany], got %v", a.ListHeaders())
+	}
+	if a.HasModule("categories") || !a.HasModule("resource") {
+		t.Errorf("expect only categories disabled")
+	}
+	exp := []annotation.Source{&annotation.TagSource{Key: "kb"}, annotation.YAMLSource{}}
+	if !reflect.DeepEqual(a.Sources(), exp) {
+		t.Errorf("expect sources %v, got %v", exp, a.Sources())
+	}
+	if lines, err := a.Adapted("+kubebuilder:validation:Enum=a;b"); err != nil || !reflect.DeepEqual(lines, []string{"+kubebuilder:validation:Enum=a,b"}) {
+		t.Errorf("expect controller-gen marker adapted, got %v, %v", lines, err)
+	}
+	for _, line := range []string{
+		"+mycompany:resource:path=foos",
+		"+mycompany:resource:path=bars,if=!enterprise",
+		"+mycompany:categories:foo",
+		"+mycompany:unknown:foo",
+	} {
+		if err := a.Parse(line); err != nil {
+			t.Errorf("unexpected error of %s: %v", line, err)
+		}
+	}
+	if !reflect.DeepEqual(done, []string{"resource"}) {
+		t.Errorf("expect handlers of [resource], got %v", done)
+	}
+
+	a.Strict(true)
+	if err := a.Parse("+mycompany:unknown:foo"); err == nil {
+		t.Errorf("expect error of unknown module in strict mode")
+	}
+}
+
+func TestCheckOptions(t *testing.T) {
+	g := Generator{Options: map[string]string{"name": "manager"}}
+	if err := g.CheckOptions("rbac", "name", "labels"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := g.CheckOptions("webhook", "patchOutputDir"); err == nil {
+		t.Errorf("expect error of unknown option")
+	}
+}
+
+func TestOverlays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	overlay := "example.com/api.Spec.Replicas:\n- +kubebuilder:validation:Maximum=10\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "overlay.yaml"), []byte(overlay), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, DefaultFile)
+	if err := ioutil.WriteFile(path, []byte("overlays: [overlay.yaml]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	lines := c.Apply(annotation.Build()).OverlayLines("example.com/api", "Spec.Replicas")
+	if len(lines) != 1 || lines[0].Text != "+kubebuilder:validation:Maximum=10" || lines[0].Position.Line != 2 {
+		t.Errorf("expect overlay annotation at line 2, got %+v", lines)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("overlays: [missing.yaml]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Errorf("expect error of missing overlay file")
+	}
+}
+
+func TestMacros(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := `headers: [mycompany]
+macros:
+  leader-election:
+  - +mycompany:rbac:groups=coordination.k8s.io,resources=leases,verbs=$(verbs)
+  - "+mycompany:rbac:groups=,resources=configmaps,verbs=$(verbs)"
+`
+	path := filepath.Join(dir, DefaultFile)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	a := c.Apply(annotation.Build())
+	lines, err := a.Expand("+mycompany:leader-election:verbs=get;update", token.Position{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	exp := []string{
+		path + ":4:5 +mycompany:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;update",
+		path + ":5:6 +mycompany:rbac:groups=,resources=configmaps,verbs=get;update",
+	}
+	got := []string{}
+	for _, l := range lines {
+		got = append(got, l.Position.String()+" "+l.Text)
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expect %v, got %v", exp, got)
+	}
+}
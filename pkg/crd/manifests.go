@@ -0,0 +1,186 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/codegen/parse"
+	"github.com/fanzhangio/go-annotation/pkg/config"
+)
+
+// ManifestOptions represent options for generating the CRD manifests. API resources are loaded from the APIs
+// directory by parse.Options, which is the input directory of the generator.
+type ManifestOptions struct {
+	parse.Options
+	OutputDir string
+
+	// project is the project file applied to registry of every run
+	project *config.Config
+}
+
+// SetDefaults sets up the default options for CRD Manifest generator.
+func (o *ManifestOptions) SetDefaults() {
+	o.Options.SetDefaults()
+	o.OutputDir = filepath.Join(".", "config", "crds")
+}
+
+// ApplyConfig overrides defaults by options of "crd" generator, strictness and features in project file, see
+// parse.Options.ApplyConfig.
+func (o *ManifestOptions) ApplyConfig(c *config.Config) error {
+	o.project = c
+	if err := o.Options.ApplyConfig(c); err != nil {
+		return err
+	}
+	if g := c.Generator("crd"); len(g.OutputDir) > 0 {
+		o.OutputDir = g.OutputDir
+	}
+	return nil
+}
+
+// Inputs returns hash of annotations of API types in the APIs directory, Go files declaring the types, and options
+// affecting the manifests, which is recorded in headers of the manifests, see annotation.InputsHash.
+func (o *ManifestOptions) Inputs() (string, error) {
+	ann := parse.AddToAnnotation(o.registry())
+	// schemas follow fields of the types, which are not annotations
+	sources, err := sourcesHash(o.APIsDir)
+	if err != nil {
+		return "", err
+	}
+	return annotation.InputsHash(o.APIsDir, ann, Modules(),
+		"domain="+o.Domain, "features="+strings.Join(o.Features, ","), "sources="+sources)
+}
+
+// Modules returns names of modules of annotations of API types, which CRD manifests follow
+func Modules() []string {
+	modules := []string{}
+	for _, m := range parse.AddToAnnotation(annotation.Build()).ListModules() {
+		modules = append(modules, m.Name)
+	}
+	return modules
+}
+
+// registry returns registry of a single run with default headers, the project file and features applied
+func (o *ManifestOptions) registry() annotation.Annotation {
+	ann := annotation.AddDefaults(annotation.Build())
+	if o.project != nil {
+		o.project.Apply(ann)
+	}
+	ann.Features(o.Features...)
+	return ann
+}
+
+// sourcesHash returns hash of Go files under dir, test files excluded
+func sourcesHash(dir string) (string, error) {
+	h := sha256.New()
+	err := afero.Walk(annotation.Fs(), dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		content, err := afero.ReadFile(annotation.Fs(), path)
+		if err != nil {
+			return err
+		}
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		h.Write(content)
+		h.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Validate validates the input options.
+func (o *ManifestOptions) Validate() error {
+	if _, err := annotation.Fs().Stat(o.APIsDir); err != nil {
+		return fmt.Errorf("invalid input directory '%s' %v", o.APIsDir, err)
+	}
+	return nil
+}
+
+// Generate generates CRD manifests of API resources in the APIs directory, one file per resource and version named
+// <group>_<version>_<kind>.yaml. Files are written to annotation.Fs, and the manifests carry header recording hash
+// of inputs, see Inputs.
+func Generate(o *ManifestOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	apis, err := o.Load()
+	if err != nil {
+		return err
+	}
+	inputs, err := o.Inputs()
+	if err != nil {
+		return fmt.Errorf("failed to hash the input dir %v", err)
+	}
+	return o.write(apis, inputs)
+}
+
+// write writes CRD manifests of given API resources with header recording inputs
+func (o *ManifestOptions) write(apis *parse.APIs, inputs string) error {
+	manifests := map[string][]byte{}
+	for group, versions := range apis.ByGroupVersionKind {
+		for version, kinds := range versions {
+			for kind, r := range kinds {
+				content, err := yaml.Marshal(r.CRD)
+				if err != nil {
+					return fmt.Errorf("failed to generate CRD manifest of %s: %v", r.CRD.Name, err)
+				}
+				name := fmt.Sprintf("%s_%s_%s.yaml", group, version, strings.ToLower(kind))
+				manifests[name] = annotation.WithGeneratedHeader(content, inputs)
+			}
+		}
+	}
+	if len(manifests) == 0 {
+		annotation.Log().V(1).Info("no API resources found", "dir", o.APIsDir)
+		return nil
+	}
+
+	fs := annotation.Fs()
+	if err := fs.MkdirAll(o.OutputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create output dir %v", err)
+	}
+	names := []string{}
+	for name := range manifests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := filepath.Join(o.OutputDir, name)
+		if err := afero.WriteFile(fs, file, manifests[name], 0666); err != nil {
+			return fmt.Errorf("failed to write CRD manifest YAML file %v", err)
+		}
+		annotation.Log().V(1).Info("wrote crd manifest", "file", file)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"k8s.io/gengo/args"
+	"k8s.io/gengo/parser"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/codegen/parse"
+)
+
+func TestWrite(t *testing.T) {
+	annotation.SetFs(afero.NewMemMapFs())
+	defer annotation.SetFs(nil)
+	pkg := "example.com/pkg/apis/ship/v1"
+	p := parser.New()
+	src := `package v1
+
+// Frigate is the Schema for the frigates API
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:path=frigates,shortName=fg
+// +kubebuilder:subresource:status
+type Frigate struct {
+	Spec FrigateSpec ` + "`json:\"spec,omitempty\"`" + `
+}
+
+// FrigateSpec defines the desired state of Frigate
+type FrigateSpec struct {
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 ` + "`json:\"replicas\"`" + `
+}
+`
+	if err := p.AddFileForTest(pkg, pkg+"/doc.go", []byte(src)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := parse.NewContext(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apis := parse.NewAPIs(ctx, args.Default(), "example.com", "example.com/pkg/apis")
+
+	o := &ManifestOptions{}
+	o.SetDefaults()
+	if err := o.write(apis, "sha256:foo"); err != nil {
+		t.Fatalf("write should have succeeded, but got error: %v", err)
+	}
+	content, err := afero.ReadFile(annotation.Fs(), "config/crds/ship_v1_frigate.yaml")
+	if err != nil {
+		t.Fatalf("expect manifest of Frigate, got error: %v", err)
+	}
+	if _, inputs, ok := annotation.ReadGeneratedHeader(content); !ok || inputs != "sha256:foo" {
+		t.Errorf("expect header recording inputs sha256:foo, got %q", content)
+	}
+	for _, exp := range []string{"name: frigates.ship.example.com", "group: ship.example.com", "shortNames:\n    - fg",
+		"status: {}", "minimum: 0"} {
+		if !strings.Contains(string(content), exp) {
+			t.Errorf("expect %q in manifest, got:\n%s", exp, content)
+		}
+	}
+}
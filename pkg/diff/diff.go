@@ -0,0 +1,263 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package diff runs generators without writing their outputs, and compares the outputs with files on disk
+// by unified diffs of YAML documents, which ignore differences of formatting and key order.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// context is the number of unchanged lines around changes in hunks of unified diff
+const context = 3
+
+// File is a generated file differing from the file on disk
+type File struct {
+	Path string
+	// Diff is unified diff from the file on disk to the generated file
+	Diff string
+}
+
+// DryRun runs generate on annotation.Fs layered by a filesystem in memory, so that generated files are kept in
+// memory and nothing is written. It returns files written under given directories which differ from the files
+// on disk, ordered by path. The filesystem is swapped during the run, so it should not run concurrently.
+func DryRun(dirs []string, generate func() error) ([]File, error) {
+	base := annotation.Fs()
+	layer := afero.NewMemMapFs()
+	annotation.SetFs(layered{afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(base), layer)})
+	defer annotation.SetFs(base)
+	if err := generate(); err != nil {
+		return nil, err
+	}
+
+	files := []File{}
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		err := afero.Walk(layer, filepath.Clean(dir), func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil || info.IsDir() || seen[path] {
+				return err
+			}
+			seen[path] = true
+			generated, err := afero.ReadFile(layer, path)
+			if err != nil {
+				return err
+			}
+			existing, err := afero.ReadFile(base, path)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if d := Unified(path, existing, generated); len(d) > 0 {
+				files = append(files, File{Path: path, Diff: d})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// layered is copy-on-write filesystem whose MkdirAll succeeds on existing directories, as os.MkdirAll does
+type layered struct {
+	afero.Fs
+}
+
+func (l layered) MkdirAll(path string, perm os.FileMode) error {
+	if dir, err := afero.IsDir(l.Fs, path); err == nil && dir {
+		return nil
+	}
+	return l.Fs.MkdirAll(path, perm)
+}
+
+// Unified returns unified diff of YAML documents from old to new content of file path, or empty string if they are
+// semantically equal. Documents are compared in normalized form, i.e. keys sorted and formatting of values unified,
+// so that only changes of values are shown. Content which is not YAML is compared as it is.
+// Nil old content is diffed as a created file.
+func Unified(path string, old, new []byte) string {
+	from, to := "a/"+path, "b/"+path
+	if old == nil {
+		from = "/dev/null"
+	}
+	a, b := lines(normalize(old)), lines(normalize(new))
+	hunks := hunks(a, b)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", from, to)
+	for _, h := range hunks {
+		buf.WriteString(h)
+	}
+	return buf.String()
+}
+
+// normalize returns YAML documents of content marshaled with sorted keys, or content itself if it is not YAML
+func normalize(content []byte) string {
+	docs := []string{}
+	for _, doc := range bytes.Split(content, []byte("---\n")) {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		j, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return string(content)
+		}
+		var v interface{}
+		if err := json.Unmarshal(j, &v); err != nil {
+			return string(content)
+		}
+		y, err := yaml.Marshal(v)
+		if err != nil {
+			return string(content)
+		}
+		docs = append(docs, string(y))
+	}
+	return strings.Join(docs, "---\n")
+}
+
+// lines splits s into lines ending with newline
+func lines(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	return strings.SplitAfter(s, "\n")[:strings.Count(s, "\n")]
+}
+
+// edit is single line of diff, op is ' ', '-' or '+'
+type edit struct {
+	op   byte
+	line string
+}
+
+// edits returns lines of a and b aligned by their longest common subsequence
+func edits(a, b []string) []edit {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	es := []edit{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			es = append(es, edit{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			es = append(es, edit{'-', a[i]})
+			i++
+		default:
+			es = append(es, edit{'+', b[j]})
+			j++
+		}
+	}
+	return es
+}
+
+// hunks returns hunks of unified diff from a to b
+func hunks(a, b []string) []string {
+	es := edits(a, b)
+	hs := []string{}
+	for start := 0; start < len(es); {
+		if es[start].op == ' ' {
+			start++
+			continue
+		}
+		// hunk spans changes separated by less than twice the context
+		first, end := start-context, start
+		for n := start; n < len(es) && n-end <= 2*context; n++ {
+			if es[n].op != ' ' {
+				end = n + 1
+			}
+		}
+		if first < 0 {
+			first = 0
+		}
+		last := end + context
+		if last > len(es) {
+			last = len(es)
+		}
+		hs = append(hs, hunk(es, first, last))
+		start = end
+	}
+	return hs
+}
+
+// hunk formats edits of range [first, last) as hunk of unified diff
+func hunk(es []edit, first, last int) string {
+	// line numbers of a and b where the hunk starts
+	aLine, bLine := 1, 1
+	for _, e := range es[:first] {
+		if e.op != '+' {
+			aLine++
+		}
+		if e.op != '-' {
+			bLine++
+		}
+	}
+	aCount, bCount := 0, 0
+	var body strings.Builder
+	for _, e := range es[first:last] {
+		if e.op != '+' {
+			aCount++
+		}
+		if e.op != '-' {
+			bCount++
+		}
+		body.WriteByte(e.op)
+		body.WriteString(e.line)
+	}
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n%s", aLine, aCount, bLine, bCount, body.String())
+}
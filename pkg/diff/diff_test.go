@@ -0,0 +1,108 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+const role = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  creationTimestamp: null
+  name: manager-role
+rules:
+- apiGroups:
+  - apps
+  resources:
+  - deployments
+  verbs:
+  - get
+`
+
+func TestUnified(t *testing.T) {
+	// formatting and key order are not differences
+	reordered := `kind: ClusterRole
+apiVersion: "rbac.authorization.k8s.io/v1"
+metadata: {name: manager-role, creationTimestamp: null}
+rules:
+  - verbs: [get]
+    apiGroups: [apps]
+    resources: [deployments]
+`
+	if d := Unified("role.yaml", []byte(role), []byte(reordered)); len(d) > 0 {
+		t.Errorf("expect no diff of reordered keys, got\n%s", d)
+	}
+
+	changed := role + "  - list\n---\nkind: ClusterRoleBinding\n"
+	exp := `--- a/role.yaml
++++ b/role.yaml
+@@ -10,3 +10,6 @@
+   - deployments
+   verbs:
+   - get
++  - list
++---
++kind: ClusterRoleBinding
+`
+	if d := Unified("role.yaml", []byte(reordered), []byte(changed)); d != exp {
+		t.Errorf("expect diff\n%s\ngot\n%s", exp, d)
+	}
+	exp = "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,1 @@\n+foo: [\n"
+	if d := Unified("new.txt", nil, []byte("foo: [\n")); d != exp {
+		t.Errorf("expect diff of created file\n%s\ngot\n%s", exp, d)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	mem := afero.NewMemMapFs()
+	if err := afero.WriteFile(mem, "config/rbac/rbac_role.yaml", []byte(role), 0644); err != nil {
+		t.Fatal(err)
+	}
+	annotation.SetFs(mem)
+	defer annotation.SetFs(nil)
+
+	files, err := DryRun([]string{"config/rbac", "config/default"}, func() error {
+		fs := annotation.Fs()
+		if err := fs.MkdirAll("config/rbac", 0755); err != nil {
+			return err
+		}
+		if err := afero.WriteFile(fs, "config/rbac/rbac_role.yaml", []byte(role+"  - watch\n"), 0644); err != nil {
+			return err
+		}
+		return afero.WriteFile(fs, "config/rbac/rbac_role_binding.yaml", []byte("kind: ClusterRoleBinding\n"), 0644)
+	})
+	if err != nil {
+		t.Fatalf("DryRun should have succeeded, but got error: %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "config/rbac/rbac_role.yaml" || files[1].Path != "config/rbac/rbac_role_binding.yaml" {
+		t.Fatalf("expect diffs of role and role binding, got %v", files)
+	}
+	if b, _ := afero.ReadFile(mem, "config/rbac/rbac_role.yaml"); string(b) != role {
+		t.Errorf("expect file on disk not written, got\n%s", b)
+	}
+	if ok, _ := afero.Exists(mem, "config/rbac/rbac_role_binding.yaml"); ok {
+		t.Errorf("expect file on disk not created")
+	}
+	if annotation.Fs() != mem {
+		t.Errorf("expect filesystem restored after dry run")
+	}
+}
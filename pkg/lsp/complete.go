@@ -0,0 +1,196 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"strings"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// Complete returns candidates of the token being typed at given position: headers and modules after "+",
+// modules after header, submodules and keys after module, and enumerated values after "key=".
+func (s *Server) Complete(text string, pos Position) []CompletionItem {
+	line := lineAt(text, pos.Line)
+	start, ok := annotationStart(line)
+	if !ok || pos.Character <= start || pos.Character > len(line) {
+		return []CompletionItem{}
+	}
+	ann := s.registry()
+	tokens := splitTokens(line[start+1 : pos.Character])
+	partial := tokens[len(tokens)-1]
+	tokens = tokens[:len(tokens)-1]
+
+	c := completer{pos: pos, partial: partial, items: []CompletionItem{}}
+	if len(tokens) == 0 {
+		for _, h := range ann.ListHeaders() {
+			c.add(h, completionKeyword, "header", "")
+		}
+		c.addModules(ann.ListModules())
+		return c.items
+	}
+	if isHeader(ann, tokens[0]) {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		c.addModules(ann.ListModules())
+		return c.items
+	}
+	m := annotation.ResolveModule(ann, strings.Join(tokens, ":"))
+	if m == nil {
+		return c.items
+	}
+
+	if !strings.ContainsAny(partial, ",=") {
+		c.addModules(subModules(m))
+		c.addParams(m)
+		return c.items
+	}
+	// completing key-value elements
+	elem := partial[strings.LastIndex(partial, ",")+1:]
+	c.partial = elem
+	if !strings.Contains(elem, "=") {
+		c.addParams(m)
+		return c.items
+	}
+	kv := strings.SplitN(elem, "=", 2)
+	p := m.GetParam(kv[0])
+	if p == nil {
+		return c.items
+	}
+	c.partial = kv[1][strings.LastIndex(kv[1], ";")+1:]
+	for _, v := range p.Values {
+		c.add(v, completionValue, p.Name, p.Doc)
+	}
+	return c.items
+}
+
+// completer collects candidates matching the partial token before cursor, which is replaced on completion.
+type completer struct {
+	pos     Position
+	partial string
+	items   []CompletionItem
+}
+
+func (c *completer) add(label string, kind int, detail, doc string) {
+	c.addText(label, label, kind, detail, doc)
+}
+
+func (c *completer) addText(label, text string, kind int, detail, doc string) {
+	if !strings.HasPrefix(label, c.partial) {
+		return
+	}
+	c.items = append(c.items, CompletionItem{
+		Label:         label,
+		Kind:          kind,
+		Detail:        detail,
+		Documentation: doc,
+		TextEdit: &TextEdit{
+			Range: Range{
+				Start: Position{Line: c.pos.Line, Character: c.pos.Character - len(c.partial)},
+				End:   c.pos,
+			},
+			NewText: text,
+		},
+	})
+}
+
+func (c *completer) addModules(modules []*annotation.Module) {
+	for _, m := range modules {
+		c.add(m.Name, completionModule, "module", m.Doc)
+	}
+}
+
+func (c *completer) addParams(m *annotation.Module) {
+	for _, p := range m.Params {
+		c.addText(p.Name, p.Name+"=", completionProperty, "key of "+m.Name, p.Doc)
+	}
+}
+
+func subModules(m *annotation.Module) []*annotation.Module {
+	modules := []*annotation.Module{}
+	for _, name := range subModuleNames(m) {
+		modules = append(modules, m.SubModules[name])
+	}
+	return modules
+}
+
+func isHeader(ann annotation.Annotation, token string) bool {
+	for _, h := range ann.ListHeaders() {
+		if h == token {
+			return true
+		}
+	}
+	return false
+}
+
+func splitTokens(s string) []string {
+	return strings.Split(s, ":")
+}
+
+// Hover returns documentation of the header, module, submodule or key under cursor.
+func (s *Server) Hover(text string, pos Position) *Hover {
+	line := lineAt(text, pos.Line)
+	start, ok := annotationStart(line)
+	if !ok || pos.Character <= start || pos.Character >= len(line) {
+		return nil
+	}
+	ann := s.registry()
+	i := ann.Resolve(line[start:])
+	if i == nil || !ann.HasModule(i.Module) {
+		return nil
+	}
+
+	// locate the colon separated token under cursor among header and modules
+	tokens := append([]string{i.Module}, i.SubModules...)
+	if len(i.Header) > 0 {
+		tokens = append([]string{i.Header}, tokens...)
+	}
+	offset := start + 1
+	for n, t := range tokens {
+		if pos.Character > offset+len(t) {
+			offset += len(t) + 1
+			continue
+		}
+		if len(i.Header) > 0 {
+			if n == 0 {
+				return hover("header **" + i.Header + "**")
+			}
+			n--
+		}
+		path := strings.Join(tokens[len(tokens)-len(i.SubModules)-1:][:n+1], ":")
+		return hover("module **" + path + "**\n\n" + annotation.ResolveModule(ann, path).Doc)
+	}
+
+	// locate the key under cursor in elements
+	m := annotation.ResolveModule(ann, i.Path())
+	for _, elem := range strings.Split(line[offset:], ",") {
+		if pos.Character <= offset+len(elem) {
+			key := strings.SplitN(elem, "=", 2)[0]
+			if p := m.GetParam(key); p != nil {
+				return hover("key **" + p.Name + "** of " + i.Path() + "\n\n" + p.Doc)
+			}
+			return nil
+		}
+		offset += len(elem) + 1
+	}
+	return nil
+}
+
+func hover(markdown string) *Hover {
+	return &Hover{Contents: markupContent{Kind: "markdown", Value: markdown}}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// annotationLine is annotation written in single line comment of a document, e.g. "// +kubebuilder:rbac:..."
+type annotationLine struct {
+	// line is zero-based line number
+	line int
+	// start is offset of "+" in the line
+	start int
+	// text is annotation text starting with "+"
+	text string
+}
+
+func (l annotationLine) textRange() Range {
+	return Range{
+		Start: Position{Line: l.line, Character: l.start},
+		End:   Position{Line: l.line, Character: l.start + len(l.text)},
+	}
+}
+
+// position returns position of the annotation in the document, whose line and column are one-based
+func (l annotationLine) position() token.Position {
+	return token.Position{Line: l.line + 1, Column: l.start + 1}
+}
+
+// annotationStart returns offset of "+" if the line is a single line comment starting with "+"
+func annotationStart(line string) (int, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(trimmed, "//") {
+		return 0, false
+	}
+	start := len(line) - len(trimmed) + 2
+	start += len(line[start:]) - len(strings.TrimLeft(line[start:], " \t"))
+	if start >= len(line) || line[start] != '+' {
+		return 0, false
+	}
+	return start, true
+}
+
+// annotationLines returns all annotations written in single line comments of the document
+func annotationLines(text string) []annotationLine {
+	lines := []annotationLine{}
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if start, ok := annotationStart(line); ok {
+			lines = append(lines, annotationLine{line: n, start: start, text: line[start:]})
+		}
+	}
+	return lines
+}
+
+// lineAt returns the line of document by zero-based line number
+func lineAt(text string, n int) string {
+	lines := strings.Split(text, "\n")
+	if n < 0 || n >= len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[n], "\r")
+}
+
+// Diagnose checks every annotation of the document, against deprecated spellings, registered modules,
+// params declared by modules, and finally by module handlers.
+func (s *Server) Diagnose(text string) []Diagnostic {
+	ann := s.registry()
+	diags := []Diagnostic{}
+	// the document is a run, duplicates of modules occurring once per run are reported.
+	// Lines are not mapped to declarations, so each line is taken as a declaration of its own
+	if err := ann.StartRun(); err != nil {
+		return diags
+	}
+	for _, l := range annotationLines(text) {
+		if err := ann.EnterType(annotation.Target{}); err != nil {
+			return diags
+		}
+		diags = append(diags, diagnose(ann, l)...)
+	}
+	return diags
+}
+
+func diagnose(ann annotation.Annotation, l annotationLine) []Diagnostic {
+	diags := []Diagnostic{}
+	report := func(severity int, format string, a ...interface{}) {
+		diags = append(diags, Diagnostic{
+			Range:    l.textRange(),
+			Severity: severity,
+			Source:   "go-annotation",
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	if replacement, ok := ann.Deprecation(l.text); ok {
+		report(SeverityWarning, "deprecated spelling, use %s", replacement)
+	}
+	// elements are checked without condition, which is evaluated on parsing
+	text, _, err := ann.Condition(l.text)
+	if err != nil {
+		report(SeverityError, "%v", err)
+		return diags
+	}
+	i := ann.Resolve(text)
+	if i != nil && len(i.Header) > 0 && !ann.HasModule(i.Module) {
+		if i.Module == annotation.DefineModule {
+			if err := ann.ParseAt(l.text, l.position()); err != nil {
+				report(SeverityError, "%v", err)
+			}
+			return diags
+		}
+		if ann.Macro(i.Module) != nil {
+			return append(diags, diagnoseMacro(ann, i.Module, l, text)...)
+		}
+	}
+	if i == nil || len(i.Header) == 0 && !ann.HasModule(i.Module) {
+		// not an annotation of registered headers, e.g. "+optional"
+		return diags
+	}
+	if !ann.HasModule(i.Module) {
+		report(SeverityWarning, "unknown module %q of header %s", i.Module, i.Header)
+		return diags
+	}
+	m := annotation.ResolveModule(ann, i.Path())
+	if m.Do == nil && len(m.SubModules) > 0 {
+		report(SeverityError, "module %s requires submodule, one of %v", i.Path(), subModuleNames(m))
+		return diags
+	}
+	if errs := m.ValidateElements(i.Elements); len(errs) > 0 {
+		for _, err := range errs {
+			report(SeverityError, "%v", err)
+		}
+		return diags
+	}
+	if annotation.HasConstRef(text) {
+		// values of constant references are resolved on generation only
+		return diags
+	}
+	if err := parse(ann, l.text); err != nil {
+		report(SeverityError, "%v", err)
+	}
+	return diags
+}
+
+// diagnoseMacro checks annotations expanded from use of macro, diagnostics are reported at the use
+// with positions of their definitions.
+func diagnoseMacro(ann annotation.Annotation, name string, l annotationLine, text string) []Diagnostic {
+	lines, err := ann.Expand(text, token.Position{})
+	if err != nil {
+		return []Diagnostic{{Range: l.textRange(), Severity: SeverityError, Source: "go-annotation", Message: err.Error()}}
+	}
+	diags := []Diagnostic{}
+	for _, e := range lines {
+		for _, d := range diagnose(ann, annotationLine{line: l.line, start: l.start, text: e.Text}) {
+			d.Range = l.textRange()
+			d.Message = fmt.Sprintf("in macro %s defined at %s: %s", name, e.Position, d.Message)
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// parse invokes module handlers of the annotation, panic of handler is reported as error
+func parse(ann annotation.Annotation, text string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("module handler failed: %v", r)
+		}
+	}()
+	return ann.Parse(text)
+}
+
+func subModuleNames(m *annotation.Module) []string {
+	names := []string{}
+	for _, sub := range m.SubModules {
+		names = append(names, sub.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CodeActions returns quick-fixes replacing deprecated spellings of annotations in given range of the document.
+func (s *Server) CodeActions(uri, text string, r Range) []CodeAction {
+	ann := s.registry()
+	actions := []CodeAction{}
+	for _, l := range annotationLines(text) {
+		if l.line < r.Start.Line || l.line > r.End.Line {
+			continue
+		}
+		replacement, ok := ann.Deprecation(l.text)
+		if !ok {
+			continue
+		}
+		actions = append(actions, CodeAction{
+			Title: "Replace with " + replacement,
+			Kind:  "quickfix",
+			Edit: &WorkspaceEdit{Changes: map[string][]TextEdit{
+				uri: {{Range: l.textRange(), NewText: replacement}},
+			}},
+		})
+	}
+	return actions
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lsp implements a language server for annotation authoring. It offers completion,
+// hover docs, diagnostics and quick-fixes of annotations backed by annotation registry.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// Server is language server of annotations. It works on documents opened by the client only,
+// so it works offline without building the workspace.
+// Character offsets of positions are counted in bytes, which equals to UTF-16 code units for annotations in ASCII.
+type Server struct {
+	// registry returns annotation with registered headers and modules. It is invoked for every diagnostics run,
+	// so module handlers always start with fresh state.
+	registry func() annotation.Annotation
+	docs     map[string]string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewServer returns language server backed by annotation registry returned by given function
+func NewServer(registry func() annotation.Annotation) *Server {
+	return &Server{
+		registry: registry,
+		docs:     map[string]string{},
+	}
+}
+
+// Serve reads requests from r and writes responses to w until the client exits or r is closed.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	reader := bufio.NewReader(r)
+	for {
+		msg, err := readMessage(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	switch msg.Method {
+	case "initialize":
+		return s.reply(msg, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // full content on change
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{"+", ":", ",", "=", ";"}},
+				"hoverProvider":      true,
+				"codeActionProvider": true,
+			},
+		})
+	case "shutdown":
+		return s.reply(msg, nil)
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil
+		}
+		s.docs[p.TextDocument.URI] = p.TextDocument.Text
+		return s.publish(p.TextDocument.URI)
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil || len(p.ContentChanges) == 0 {
+			return nil
+		}
+		s.docs[p.TextDocument.URI] = p.ContentChanges[len(p.ContentChanges)-1].Text
+		return s.publish(p.TextDocument.URI)
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/completion":
+		var p positionParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return s.replyError(msg, codeInvalidParams, err.Error())
+		}
+		return s.reply(msg, s.Complete(s.docs[p.TextDocument.URI], p.Position))
+	case "textDocument/hover":
+		var p positionParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return s.replyError(msg, codeInvalidParams, err.Error())
+		}
+		return s.reply(msg, s.Hover(s.docs[p.TextDocument.URI], p.Position))
+	case "textDocument/codeAction":
+		var p codeActionParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return s.replyError(msg, codeInvalidParams, err.Error())
+		}
+		return s.reply(msg, s.CodeActions(p.TextDocument.URI, s.docs[p.TextDocument.URI], p.Range))
+	}
+	if msg.ID != nil {
+		return s.replyError(msg, codeMethodNotFound, fmt.Sprintf("method %s is not supported", msg.Method))
+	}
+	// notifications not supported are ignored
+	return nil
+}
+
+func (s *Server) publish(uri string) error {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: s.Diagnose(s.docs[uri]),
+	})
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   *responseError   `json:"error"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+func (s *Server) reply(msg *message, result interface{}) error {
+	return s.write(response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+func (s *Server) replyError(msg *message, code int, text string) error {
+	return s.write(errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: &responseError{Code: code, Message: text}})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// write writes message with base protocol header
+func (s *Server) write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n", len(b)); err != nil {
+		return err
+	}
+	_, err = s.w.Write(b)
+	return err
+}
+
+// readMessage reads single message with base protocol header
+func readMessage(r *bufio.Reader) (*message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length header: %v", err)
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	msg := &message{}
+	if err := json.Unmarshal(b, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+func testRegistry() annotation.Annotation {
+	a := annotation.Build()
+	a.Header("kubebuilder")
+	a.Deprecate("+rbac", "+kubebuilder:rbac")
+	a.Module(&annotation.Module{
+		Name: "rbac",
+		Doc:  "RBAC rule",
+		Params: []annotation.Param{
+			{Name: "groups", Doc: "API groups"},
+			{Name: "verbs", Doc: "verbs", Required: true},
+		},
+		Do: func(string) error { return nil },
+	})
+	a.Module(&annotation.Module{
+		Name: "webhook",
+		SubModules: map[string]*annotation.Module{
+			"admission": &annotation.Module{
+				Name: "admission",
+				Params: []annotation.Param{
+					{Name: "type", Values: []string{"mutating", "validating"}},
+					{Name: "port"},
+				},
+				Do: func(s string) error {
+					if strings.Contains(s, "port=x") {
+						return fmt.Errorf("invalid port")
+					}
+					return nil
+				},
+			},
+		},
+	})
+	a.Define(&annotation.Macro{Name: "reader", Lines: []annotation.Line{
+		{Text: "+kubebuilder:rbac:groups=$(groups),verbs=get;list;watch"},
+	}})
+	a.Define(&annotation.Macro{Name: "broken", Lines: []annotation.Line{
+		{Text: "+kubebuilder:rbac:groups=apps", Position: token.Position{Filename: "macros.yaml", Line: 2, Column: 3}},
+	}})
+	return a
+}
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		line string
+		exp  []string
+	}{
+		{line: "// +kubebuilder:rbac:groups=apps,verbs=get", exp: []string{}},
+		{line: "// +optional", exp: []string{}},
+		{line: "// +rbac:groups=apps,verbs=get", exp: []string{"deprecated spelling, use +kubebuilder:rbac:groups=apps,verbs=get"}},
+		{line: "// +kubebuilder:rbac:groups=apps", exp: []string{`missing required key "verbs" for module rbac`}},
+		{line: "// +kubebuilder:rbac:group=apps,verbs=get", exp: []string{`unknown key "group" for module rbac`}},
+		{line: "// +kubebuilder:webhook:admission:type=foo", exp: []string{`invalid value "foo" of key type, expect one of [mutating validating]`}},
+		{line: "// +kubebuilder:webhook:admission:port=x", exp: []string{"invalid port"}},
+		{line: "// +kubebuilder:webhook:type=mutating", exp: []string{"module webhook requires submodule, one of [admission]"}},
+		{line: "// +kubebuilder:foo:bar", exp: []string{`unknown module "foo" of header kubebuilder`}},
+		{line: "// +kubebuilder:reader:groups=apps", exp: []string{}},
+		{line: "// +kubebuilder:rbac:groups=apps,verbs=get,if=enterprise", exp: []string{}},
+		{line: "// +kubebuilder:reader:groups=apps,if=!enterprise", exp: []string{}},
+		{line: "// +kubebuilder:rbac:groups=apps,if=enterprise", exp: []string{`missing required key "verbs" for module rbac`}},
+		{line: "// +kubebuilder:reader:group=apps", exp: []string{"macro reader has no parameter group, expect one of [groups]"}},
+		{line: "// +kubebuilder:broken", exp: []string{`in macro broken defined at macros.yaml:2:3: missing required key "verbs" for module rbac`}},
+		{line: "// +kubebuilder:define:writer", exp: []string{`3:4: invalid definition of macro "+kubebuilder:define:writer", expect +kubebuilder:define:<name>:<annotation>`}},
+	}
+	s := NewServer(testRegistry)
+	for _, test := range tests {
+		messages := []string{}
+		for _, d := range s.Diagnose("package foo\n\n" + test.line + "\n") {
+			messages = append(messages, d.Message)
+			if d.Range.Start.Line != 2 || d.Range.Start.Character != 3 {
+				t.Errorf("diagnostic of %q should start at 2:3, got %+v", test.line, d.Range.Start)
+			}
+		}
+		if !reflect.DeepEqual(messages, test.exp) {
+			t.Errorf("diagnostics of %q should have matched, expected %v and got %v", test.line, test.exp, messages)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		line string
+		exp  []string
+	}{
+		{line: "// +", exp: []string{"kubebuilder", "rbac", "webhook"}},
+		{line: "// +kubebuilder:w", exp: []string{"webhook"}},
+		{line: "// +kubebuilder:webhook:", exp: []string{"admission"}},
+		{line: "// +kubebuilder:rbac:", exp: []string{"groups=", "verbs="}},
+		{line: "// +kubebuilder:rbac:groups=apps,v", exp: []string{"verbs="}},
+		{line: "// +kubebuilder:webhook:admission:type=m", exp: []string{"mutating"}},
+		{line: "// +kubebuilder:foo:", exp: []string{}},
+	}
+	s := NewServer(testRegistry)
+	for _, test := range tests {
+		texts := []string{}
+		for _, item := range s.Complete(test.line, Position{Line: 0, Character: len(test.line)}) {
+			texts = append(texts, item.TextEdit.NewText)
+		}
+		if !reflect.DeepEqual(texts, test.exp) {
+			t.Errorf("completion of %q should have matched, expected %v and got %v", test.line, test.exp, texts)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	line := "// +kubebuilder:rbac:groups=apps,verbs=get"
+	s := NewServer(testRegistry)
+	tests := map[int]string{
+		5:  "header **kubebuilder**",
+		18: "module **rbac**\n\nRBAC rule",
+		35: "key **verbs** of rbac\n\nverbs",
+	}
+	for character, exp := range tests {
+		h := s.Hover(line, Position{Line: 0, Character: character})
+		if h == nil || h.Contents.Value != exp {
+			t.Errorf("hover at %d should have been %q, got %+v", character, exp, h)
+		}
+	}
+}
+
+func TestCodeActions(t *testing.T) {
+	s := NewServer(testRegistry)
+	text := "package foo\n\n// +rbac:groups=apps,verbs=get\n"
+	actions := s.CodeActions("file:///foo.go", text, Range{Start: Position{Line: 2}, End: Position{Line: 2}})
+	if len(actions) != 1 {
+		t.Fatalf("expected one quick-fix, got %+v", actions)
+	}
+	edit := actions[0].Edit.Changes["file:///foo.go"][0]
+	if edit.NewText != "+kubebuilder:rbac:groups=apps,verbs=get" || edit.Range.Start.Character != 3 {
+		t.Errorf("quick-fix should replace deprecated spelling, got %+v", edit)
+	}
+}
@@ -18,15 +18,18 @@ package rbac
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/ghodss/yaml"
+	"github.com/spf13/afero"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/config"
 )
 
 // ManifestOptions represent options for generating the RBAC manifests.
@@ -35,6 +38,11 @@ type ManifestOptions struct {
 	OutputDir string
 	Name      string
 	Labels    map[string]string
+	// Features are feature flags enabled for annotations conditional on them, see annotation.ConditionKey
+	Features []string
+
+	// project is the project file applied to registry of every run
+	project *config.Config
 }
 
 // SetDefaults sets up the default options for RBAC Manifest generator.
@@ -44,6 +52,39 @@ func (o *ManifestOptions) SetDefaults() {
 	o.OutputDir = filepath.Join(".", "config", "rbac")
 }
 
+// ApplyConfig overrides defaults by options of "rbac" generator and features in project file. Options are "name" of
+// the role, and "labels" of the manifests formatted as key1=value1,key2=value2.
+func (o *ManifestOptions) ApplyConfig(c *config.Config) error {
+	o.project = c
+	g := c.Generator("rbac")
+	if err := g.CheckOptions("rbac", "name", "labels"); err != nil {
+		return err
+	}
+	if len(g.InputDir) > 0 {
+		o.InputDir = g.InputDir
+	}
+	if len(g.OutputDir) > 0 {
+		o.OutputDir = g.OutputDir
+	}
+	if len(c.Features) > 0 {
+		o.Features = c.Features
+	}
+	if name, ok := g.Option("name"); ok {
+		o.Name = name
+	}
+	if labels, ok := g.Option("labels"); ok {
+		o.Labels = map[string]string{}
+		for _, elem := range strings.Split(labels, ",") {
+			key, value, err := annotation.ParseKV(elem)
+			if err != nil {
+				return fmt.Errorf("invalid labels %q of generator rbac: %v", labels, err)
+			}
+			o.Labels[key] = value
+		}
+	}
+	return nil
+}
+
 // RoleName returns the RBAC role name to be used in the manifests.
 func (o *ManifestOptions) RoleName() string {
 	return o.Name + "-role"
@@ -60,16 +101,43 @@ func (o *ManifestOptions) Namespace() string {
 	return "system"
 }
 
+// Inputs returns hash of RBAC annotations in the input directory and options affecting the manifests,
+// which is recorded in headers of the manifests, see annotation.InputsHash.
+func (o *ManifestOptions) Inputs() (string, error) {
+	return o.inputs(AddToAnnotation(o.registry()))
+}
+
+// registry returns registry of a single run with default headers, the project file and features applied, so modules
+// and features of the run don't leak into other runs
+func (o *ManifestOptions) registry() annotation.Annotation {
+	ann := annotation.AddDefaults(annotation.Build())
+	if o.project != nil {
+		o.project.Apply(ann)
+	}
+	ann.Features(o.Features...)
+	return ann
+}
+
+func (o *ManifestOptions) inputs(ann annotation.Annotation) (string, error) {
+	options := []string{"name=" + o.Name, "features=" + strings.Join(o.Features, ",")}
+	for key, value := range o.Labels {
+		options = append(options, "label="+key+"="+value)
+	}
+	sort.Strings(options[2:])
+	return annotation.InputsHash(o.InputDir, ann, []string{"rbac"}, options...)
+}
+
 // Validate validates the input options.
 func (o *ManifestOptions) Validate() error {
-	if _, err := os.Stat(o.InputDir); err != nil {
+	if _, err := annotation.Fs().Stat(o.InputDir); err != nil {
 		return fmt.Errorf("invalid input directory '%s' %v", o.InputDir, err)
 	}
 	return nil
 }
 
 // Generate generates RBAC manifests by parsing the RBAC annotations in Go source
-// files specified in the input directory.
+// files specified in the input directory. Files are read from and written to annotation.Fs,
+// and the manifests carry header recording hash of inputs, see Inputs.
 func Generate(o *ManifestOptions) error {
 	if err := o.Validate(); err != nil {
 		return err
@@ -79,36 +147,47 @@ func Generate(o *ManifestOptions) error {
 		rules: []rbacv1.PolicyRule{},
 	}
 	// parse rbac annotation by generic annotation approach
-	err := annotation.ParseAnnotationByDir(o.InputDir, ops.AddToAnnotation(annotation.GetAnnotation()))
+	ann := ops.AddToAnnotation(o.registry())
+	err := annotation.ParseAnnotationByDir(o.InputDir, ann)
 	if err != nil {
 		return fmt.Errorf("failed to parse the input dir %v", err)
 	}
 	if len(ops.rules) == 0 {
+		annotation.Log().V(1).Info("no rbac rules found", "dir", o.InputDir)
 		return nil
 	}
+	inputs, err := o.inputs(ann)
+	if err != nil {
+		return fmt.Errorf("failed to hash the input dir %v", err)
+	}
 	roleManifest, err := getClusterRoleManifest(ops.rules, o)
 	if err != nil {
 		return fmt.Errorf("failed to generate role manifest %v", err)
 	}
+	roleManifest = annotation.WithGeneratedHeader(roleManifest, inputs)
 
 	roleBindingManifest, err := getClusterRoleBindingManifest(o)
 	if err != nil {
 		return fmt.Errorf("failed to generate role binding manifests %v", err)
 	}
+	roleBindingManifest = annotation.WithGeneratedHeader(roleBindingManifest, inputs)
 
-	err = os.MkdirAll(o.OutputDir, os.ModePerm)
+	fs := annotation.Fs()
+	err = fs.MkdirAll(o.OutputDir, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("failed to create output dir %v", err)
 	}
 	roleManifestFile := filepath.Join(o.OutputDir, "rbac_role.yaml")
-	if err := ioutil.WriteFile(roleManifestFile, roleManifest, 0666); err != nil {
+	if err := afero.WriteFile(fs, roleManifestFile, roleManifest, 0666); err != nil {
 		return fmt.Errorf("failed to write role manifest YAML file %v", err)
 	}
+	annotation.Log().V(1).Info("wrote rbac manifest", "file", roleManifestFile, "rules", len(ops.rules))
 
 	roleBindingManifestFile := filepath.Join(o.OutputDir, "rbac_role_binding.yaml")
-	if err := ioutil.WriteFile(roleBindingManifestFile, roleBindingManifest, 0666); err != nil {
+	if err := afero.WriteFile(fs, roleBindingManifestFile, roleBindingManifest, 0666); err != nil {
 		return fmt.Errorf("failed to write role manifest YAML file %v", err)
 	}
+	annotation.Log().V(1).Info("wrote rbac manifest", "file", roleBindingManifestFile)
 	return nil
 }
 
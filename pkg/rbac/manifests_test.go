@@ -0,0 +1,68 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+func TestGenerate(t *testing.T) {
+	annotation.SetFs(afero.NewMemMapFs())
+	defer annotation.SetFs(nil)
+	content := `package controller
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get
+// +kubebuilder:rbac:groups=ship,resources=licenses,verbs=get,if=enterprise
+func reconcile() {}
+`
+	if err := afero.WriteFile(annotation.Fs(), "/pkg/controller/controller.go", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generate := func(features ...string) string {
+		o := &ManifestOptions{}
+		o.SetDefaults()
+		o.InputDir, o.OutputDir, o.Features = "/pkg", "/config/rbac", features
+		if err := Generate(o); err != nil {
+			t.Fatalf("Generate should have succeeded, but got error: %v", err)
+		}
+		role, err := afero.ReadFile(annotation.Fs(), filepath.Join(o.OutputDir, "rbac_role.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(role)
+	}
+
+	// features and modules of a generation don't leak into later generations
+	if role := generate("enterprise"); !strings.Contains(role, "licenses") {
+		t.Errorf("expect rule of enabled feature, got %s", role)
+	}
+	if role := generate(); strings.Contains(role, "licenses") || !strings.Contains(role, "deployments") {
+		t.Errorf("expect rule of disabled feature omitted, got %s", role)
+	}
+	if _, err := (&ManifestOptions{InputDir: "/pkg"}).Inputs(); err != nil {
+		t.Fatalf("Inputs should have succeeded, but got error: %v", err)
+	}
+	if annotation.GetAnnotation().HasModule("rbac") {
+		t.Errorf("expect rbac module not registered into the global registry")
+	}
+}
@@ -19,7 +19,7 @@ limitations under the License.
 package rbac
 
 import (
-	"log"
+	"fmt"
 	"strings"
 
 	"github.com/fanzhangio/go-annotation/pkg/annotation"
@@ -40,10 +40,23 @@ func (o *parserOptions) AddToAnnotation(a annotation.Annotation) annotation.Anno
 	a.Module(&annotation.Module{
 		Name: "rbac",
 		Do:   o.ParseRBAC,
+		Doc:  "RBAC policy rule granted to the controller manager, e.g. +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list",
+		Params: []annotation.Param{
+			{Name: "groups", Doc: "API groups of the rule split by semicolon, \"core\" refers to the core group"},
+			{Name: "resources", Doc: "resources of the rule split by semicolon"},
+			{Name: "verbs", Doc: "verbs of the rule split by semicolon", Required: true},
+			{Name: "urls", Doc: "non-resource URLs of the rule split by semicolon"},
+		},
 	})
 	return a
 }
 
+// AddToAnnotation registers rbac module into given annotation, which collects rules only for
+// inspecting annotations, e.g. by language server.
+func AddToAnnotation(a annotation.Annotation) annotation.Annotation {
+	return (&parserOptions{}).AddToAnnotation(a)
+}
+
 // parseRBACTag parses the given RBAC annotation in to an RBAC PolicyRule.
 // This is copied from Kubebuilder code.
 func (o *parserOptions) ParseRBAC(tag string) (err error) {
@@ -51,7 +64,7 @@ func (o *parserOptions) ParseRBAC(tag string) (err error) {
 	for _, elem := range strings.Split(tag, ",") {
 		key, value, err := annotation.ParseKV(elem)
 		if err != nil {
-			log.Fatalf("// +kubebuilder:rbac: tags must be key value pairs.  Expected "+
+			return fmt.Errorf("// +kubebuilder:rbac: tags must be key value pairs.  Expected "+
 				"keys [groups=<group1;group2>,resources=<resource1;resource2>,verbs=<verb1;verb2>] "+
 				"Got string: [%s]", tag)
 		}
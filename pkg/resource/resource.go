@@ -0,0 +1,164 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package resource registers the resource module of API types, e.g. +kubebuilder:resource:path=foos,shortName=fo.
+// It doesn't load API types, so generators requiring resources declared in their run can import it cheaply.
+package resource
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/markbates/inflect"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// TypeModules are modules of other annotations of API types. Runs parsing API types by resource module alone may
+// disable them, see annotation.Annotation.Disable.
+var TypeModules = []string{"subresource-request", "subresource", "validation", "categories", "printcolumn", "field", "nonNamespaced"}
+
+// Resource is the resource declared by annotation of a type
+type Resource struct {
+	// Resource is the plural name declared by path - e.g. peachescastles
+	Resource string
+	// ShortName is the short name of the resource - e.g. pc
+	ShortName string
+}
+
+// Resources is the result of resource annotations in a run.
+type Resources struct {
+	// Current is the resource declared by annotation of the current type
+	Current Resource
+	// ByGroup is plural names of resources declared in the run by full group name - e.g. mushroomkingdom.k8s.io
+	ByGroup map[string]sets.String
+
+	group func(t annotation.Target) string
+}
+
+// NewResources returns resources grouped by given func, which returns full group name of the type. Groups are named
+// by DocGroup of package directories if it is nil.
+func NewResources(group func(t annotation.Target) string) *Resources {
+	if group == nil {
+		group = func(t annotation.Target) string { return DocGroup(t.Package) }
+	}
+	return &Resources{ByGroup: map[string]sets.String{}, group: group}
+}
+
+// AddToAnnotation registers resource module, its result is r.
+func (r *Resources) AddToAnnotation(a annotation.Annotation) annotation.Annotation {
+	var declared bool
+	a.Module(&annotation.Module{
+		Name:        "resource",
+		Meta:        r,
+		Doc:         "API resource of the type, e.g. +kubebuilder:resource:path=services,shortName=svc",
+		Cardinality: annotation.OncePerTarget,
+		Merge:       annotation.MergeKeys,
+		Params: []annotation.Param{
+			{Name: "path", Doc: "plural resource name of the type"},
+			{Name: "shortName", Doc: "short name of the resource"},
+		},
+		Hooks: annotation.Hooks{
+			OnStartRun: func() error {
+				r.ByGroup = map[string]sets.String{}
+				return nil
+			},
+			OnEnterType: func(annotation.Target) error {
+				r.Current = Resource{}
+				declared = false
+				return nil
+			},
+			OnLeaveType: func(t annotation.Target) error {
+				if !declared {
+					return nil
+				}
+				resource := r.Current.Resource
+				if resource == "" {
+					resource = strings.ToLower(inflect.Pluralize(t.Name))
+				}
+				group := r.group(t)
+				if _, f := r.ByGroup[group]; !f {
+					r.ByGroup[group] = sets.NewString()
+				}
+				r.ByGroup[group].Insert(resource)
+				return nil
+			},
+		},
+		Do: func(commentText string) error {
+			declared = true
+			for _, elem := range strings.Split(commentText, ",") {
+				key, value, err := annotation.ParseKV(elem)
+				if err != nil {
+					return fmt.Errorf("// +kubebuilder:resource: tags must be key value pairs.  Expected "+
+						"keys [path=<resourcepath>] "+
+						"Got string: [%s]", commentText)
+				}
+				switch key {
+				case "path":
+					r.Current.Resource = value
+				case "shortName":
+					r.Current.ShortName = value
+				default:
+					return fmt.Errorf("The given input %s is invalid", value)
+				}
+			}
+			return nil
+		},
+	})
+	return a
+}
+
+// FullGroup returns full name of group in domain - e.g. mushroomkingdom.k8s.io, the group alone if domain is empty.
+func FullGroup(group, domain string) string {
+	if len(domain) == 0 {
+		return group
+	}
+	return group + "." + domain
+}
+
+// DocGroup returns full group name of API package directory dir, which is either group or version directory under
+// the APIs directory, e.g. pkg/apis/ship/v1. It is "+groupName" of doc.go of dir if declared, otherwise the name of
+// the group directory in "+domain" of doc.go of the APIs directory.
+func DocGroup(dir string) string {
+	if group := docTag(dir, "groupName"); len(group) > 0 {
+		return group
+	}
+	group := dir
+	if base := filepath.Base(filepath.Dir(dir)); base != "apis" && base != "api" {
+		group = filepath.Dir(dir)
+	}
+	return FullGroup(filepath.Base(group), docTag(filepath.Dir(group), "domain"))
+}
+
+// docTag returns value of "+name=value" comment in doc.go of directory dir, empty if it is missing
+func docTag(dir, name string) string {
+	file, err := annotation.Fs().Open(filepath.Join(dir, "doc.go"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	prefix := "+" + name + "="
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "//"))
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/spf13/afero"
+)
+
+func TestResources(t *testing.T) {
+	annotation.SetFs(afero.NewMemMapFs())
+	defer annotation.SetFs(nil)
+	files := map[string]string{
+		"/pkg/apis/doc.go": "// +domain=example.com\npackage apis\n",
+		"/pkg/apis/ship/v1/frigate.go": `package v1
+
+// +kubebuilder:resource:path=frigates,shortName=fg
+type Frigate struct{}
+
+// +kubebuilder:resource:shortName=cr
+type Cruiser struct{}
+
+type FrigateList struct{}
+`,
+		"/pkg/apis/crew/doc.go": "// +groupName=crew.fleet.io\npackage crew\n",
+		"/pkg/apis/crew/captain.go": `package crew
+
+// +kubebuilder:resource:shortName=cp
+type Captain struct{}
+`,
+	}
+	for name, content := range files {
+		if err := afero.WriteFile(annotation.Fs(), name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewResources(nil)
+	if err := annotation.ParseAnnotationByDir("/pkg/apis", r.AddToAnnotation(annotation.AddDefaults(annotation.Build()))); err != nil {
+		t.Fatal(err)
+	}
+	// groups are full names, by domain of the APIs directory or +groupName of the package
+	if exp := []string{"cruisers", "frigates"}; len(r.ByGroup) != 2 || !r.ByGroup["ship.example.com"].HasAll(exp...) ||
+		r.ByGroup["ship.example.com"].Len() != len(exp) || !r.ByGroup["crew.fleet.io"].Has("captains") {
+		t.Errorf("expect resources by full groups, got %v", r.ByGroup)
+	}
+}
+
+func TestDocGroup(t *testing.T) {
+	annotation.SetFs(afero.NewMemMapFs())
+	defer annotation.SetFs(nil)
+	if err := afero.WriteFile(annotation.Fs(), "/pkg/apis/doc.go", []byte("// +domain=example.com\npackage apis\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"/pkg/apis/ship/v1": "ship.example.com",
+		"/pkg/apis/ship":    "ship.example.com",
+		"/other/apis/ship":  "ship",
+	}
+	for dir, exp := range tests {
+		if group := DocGroup(dir); group != exp {
+			t.Errorf("expect group %s of %s, got %s", exp, dir, group)
+		}
+	}
+}
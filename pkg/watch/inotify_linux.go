@@ -0,0 +1,185 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// events are inotify events of directories reporting changed files, files are written in place,
+// replaced by rename, or removed
+const events = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// Watcher reports changed files under watched directories by Linux inotify, Go files by default. Directories
+// created later are watched too. Next and Close should not be called concurrently.
+type Watcher struct {
+	// Debounce is the quiet period closing a batch of changes, DefaultDebounce by default
+	Debounce time.Duration
+	// Files selects reported files, e.g. Session.Parsed, Go files are reported if it is nil
+	Files func(path string) bool
+	fd    int
+	epfd  int
+	// dirs are watched directories by watch descriptors
+	dirs map[int32]string
+}
+
+// NewWatcher returns watcher of given directories and their subdirectories
+func NewWatcher(dirs ...string) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("epoll_create1", err)
+	}
+	w := &Watcher{Debounce: DefaultDebounce, fd: fd, epfd: epfd, dirs: map[int32]string{}}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}); err != nil {
+		w.Close()
+		return nil, os.NewSyscallError("epoll_ctl", err)
+	}
+	for _, dir := range dirs {
+		if _, err := w.add(dir); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// reported returns true if changes of file of given path are reported
+func (w *Watcher) reported(path string) bool {
+	if w.Files != nil {
+		return w.Files(path)
+	}
+	return goFile(path)
+}
+
+// add watches directory and its subdirectories, and returns reported files found in them
+func (w *Watcher) add(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if w.reported(path) {
+				files = append(files, path)
+			}
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, events)
+		if err != nil {
+			return &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+		}
+		w.dirs[int32(wd)] = path
+		return nil
+	})
+	return files, err
+}
+
+// Next blocks until Go files change, and returns the changed files once no more change follows within Debounce
+func (w *Watcher) Next() ([]string, error) {
+	changed := map[string]bool{}
+	timeout := -1
+	ready := make([]syscall.EpollEvent, 1)
+	for {
+		n, err := syscall.EpollWait(w.epfd, ready, timeout)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("epoll_wait", err)
+		}
+		if n == 0 {
+			paths := []string{}
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			return paths, nil
+		}
+		paths, err := w.read()
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			changed[path] = true
+		}
+		if len(changed) > 0 {
+			timeout = int(w.Debounce / time.Millisecond)
+		}
+	}
+}
+
+// read returns Go files of pending inotify events
+func (w *Watcher) read() ([]string, error) {
+	paths := []string{}
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			return paths, nil
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("read", err)
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[start:start+int(event.Len)], "\x00"))
+			offset = start + int(event.Len)
+
+			dir, ok := w.dirs[event.Wd]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, event.Wd)
+			}
+			if !ok || len(name) == 0 {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if event.Mask&syscall.IN_ISDIR != 0 {
+				if event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					// files may be written into new directory before it is watched
+					files, err := w.add(path)
+					if err != nil && !os.IsNotExist(err) {
+						return nil, err
+					}
+					paths = append(paths, files...)
+				}
+				continue
+			}
+			if w.reported(path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	syscall.Close(w.epfd)
+	return syscall.Close(w.fd)
+}
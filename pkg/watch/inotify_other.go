@@ -0,0 +1,48 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watch
+
+import (
+	"fmt"
+	"time"
+)
+
+// Watcher reports changed files under watched directories, which requires Linux inotify
+type Watcher struct {
+	// Debounce is the quiet period closing a batch of changes, DefaultDebounce by default
+	Debounce time.Duration
+	// Files selects reported files, e.g. Session.Parsed, Go files are reported if it is nil
+	Files func(path string) bool
+}
+
+// NewWatcher returns error, as watching directories requires Linux inotify
+func NewWatcher(dirs ...string) (*Watcher, error) {
+	return nil, fmt.Errorf("watching directories requires Linux inotify")
+}
+
+// Next returns error, as watching directories requires Linux inotify
+func (w *Watcher) Next() ([]string, error) {
+	return nil, fmt.Errorf("watching directories requires Linux inotify")
+}
+
+// Close does nothing
+func (w *Watcher) Close() error {
+	return nil
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package watch watches Go files of input packages and re-scans annotations of changed files only,
+// so that generators are rerun only if annotations of their modules change.
+package watch
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+// DefaultDebounce is the quiet period closing a batch of changes, so that rapid saves are reported once
+const DefaultDebounce = 200 * time.Millisecond
+
+// Session keeps annotations of every Go file under watched directories, and updates annotations of
+// changed files by scanning them through the registry. Module handlers are not invoked.
+// Files are read from annotation.Fs, so sessions work on unsaved buffers of editors too.
+type Session struct {
+	ann   annotation.Annotation
+	files map[string]*sourceFile
+}
+
+// sourceFile is annotations of single Go file, and whether the file references Go constants
+type sourceFile struct {
+	instances []*annotation.Instance
+	constRefs bool
+}
+
+// NewSession returns session scanning annotations by given registry
+func NewSession(ann annotation.Annotation) *Session {
+	return &Session{ann: ann, files: map[string]*sourceFile{}}
+}
+
+// Load scans annotations of all parsed files under given directories, see Parsed
+func (s *Session) Load(dirs ...string) error {
+	for _, dir := range dirs {
+		err := afero.Walk(annotation.Fs(), dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !s.Parsed(path) {
+				return err
+			}
+			f, err := s.scan(path)
+			if err != nil {
+				return err
+			}
+			s.files[path] = f
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Instances returns annotations of all files ordered by file
+func (s *Session) Instances() []*annotation.Instance {
+	paths := []string{}
+	for path := range s.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	instances := []*annotation.Instance{}
+	for _, path := range paths {
+		instances = append(instances, s.files[path].instances...)
+	}
+	return instances
+}
+
+// Update re-scans annotations of given changed files, files not existing anymore are dropped. Other files of the
+// same packages referencing Go constants are re-scanned too, as the constants may be declared in changed files.
+// Files failing to scan, e.g. saved in the middle of editing, keep their previous annotations.
+func (s *Session) Update(paths []string) *Change {
+	c := &Change{ann: s.ann}
+	changed := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, path := range paths {
+		if s.Parsed(path) {
+			changed[path] = true
+			dirs[filepath.Dir(path)] = true
+		}
+	}
+	for path, f := range s.files {
+		if f.constRefs && dirs[filepath.Dir(path)] {
+			changed[path] = true
+		}
+	}
+	for path := range changed {
+		c.Files = append(c.Files, path)
+	}
+	sort.Strings(c.Files)
+
+	for _, path := range c.Files {
+		old := s.files[path]
+		if old == nil {
+			old = &sourceFile{}
+		}
+		f := &sourceFile{}
+		if _, err := annotation.Fs().Stat(path); err == nil {
+			if f, err = s.scan(path); err != nil {
+				c.Errors = append(c.Errors, err)
+				continue
+			}
+			s.files[path] = f
+		} else if os.IsNotExist(err) {
+			delete(s.files, path)
+		} else {
+			c.Errors = append(c.Errors, err)
+			continue
+		}
+		added, removed := diff(old.instances, f.instances)
+		c.Added = append(c.Added, added...)
+		c.Removed = append(c.Removed, removed...)
+	}
+	return c
+}
+
+// scan resolves annotations of single file
+func (s *Session) scan(path string) (*sourceFile, error) {
+	src, err := afero.ReadFile(annotation.Fs(), path)
+	if err != nil {
+		return nil, err
+	}
+	idx := annotation.NewIndex()
+	if err := annotation.ScanByFile(token.NewFileSet(), path, nil, s.ann, idx); err != nil {
+		return nil, err
+	}
+	return &sourceFile{instances: idx.Instances(), constRefs: annotation.HasConstRef(string(src))}, nil
+}
+
+// diff returns annotations added to and removed from a file. Annotations are compared by declaration and text,
+// so that annotations moved by edits of lines above them are not reported.
+func diff(old, new []*annotation.Instance) (added, removed []*annotation.Instance) {
+	key := func(i *annotation.Instance) string {
+		return i.Target + "\x00" + i.Text
+	}
+	count := map[string]int{}
+	for _, i := range old {
+		count[key(i)]++
+	}
+	for _, i := range new {
+		if count[key(i)] > 0 {
+			count[key(i)]--
+			continue
+		}
+		added = append(added, i)
+	}
+	for n := len(old) - 1; n >= 0; n-- {
+		if count[key(old[n])] > 0 {
+			count[key(old[n])]--
+			removed = append([]*annotation.Instance{old[n]}, removed...)
+		}
+	}
+	return added, removed
+}
+
+// Change is annotations added and removed by single update
+type Change struct {
+	ann annotation.Annotation
+	// Files are files re-scanned
+	Files   []string
+	Added   []*annotation.Instance
+	Removed []*annotation.Instance
+	// Errors are errors of files failing to scan
+	Errors []error
+}
+
+// Empty returns true if no annotation is added or removed
+func (c *Change) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// Affects returns true if annotations of any of given modules are added or removed. Uses of macros are
+// checked by annotations they expand into. Changed definitions of macros, and annotations of unknown modules
+// of registered headers, which may be uses of macros defined in other files, affect all modules.
+func (c *Change) Affects(modules ...string) bool {
+	affected := map[string]bool{}
+	for _, m := range modules {
+		affected[m] = true
+	}
+	for _, i := range append(append([]*annotation.Instance{}, c.Added...), c.Removed...) {
+		if i.Module == annotation.DefineModule {
+			return true
+		}
+		lines, err := c.ann.Expand(i.Text, i.Position)
+		if err != nil {
+			return true
+		}
+		for _, l := range lines {
+			e := c.ann.Resolve(l.Text)
+			if e == nil {
+				continue
+			}
+			if affected[e.Module] || len(e.Header) > 0 && !c.ann.HasModule(e.Module) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Summary returns lines of added and removed annotations, and errors of files failing to scan
+func (c *Change) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d files changed, %d annotations added, %d removed\n", len(c.Files), len(c.Added), len(c.Removed))
+	for _, i := range c.Added {
+		fmt.Fprintf(&b, "+ %s\n", line(i))
+	}
+	for _, i := range c.Removed {
+		fmt.Fprintf(&b, "- %s\n", line(i))
+	}
+	for _, err := range c.Errors {
+		fmt.Fprintf(&b, "! %v\n", err)
+	}
+	return b.String()
+}
+
+func line(i *annotation.Instance) string {
+	s := fmt.Sprintf("%s:%d: %s", i.Position.Filename, i.Position.Line, i.Text)
+	if len(i.Target) > 0 {
+		s += " (" + i.Target + ")"
+	}
+	return s
+}
+
+// Parsed returns true if annotations of file of given path are parsed, i.e. Go files and files read by sources of
+// the registry, see annotation.Source
+func (s *Session) Parsed(path string) bool {
+	if goFile(path) {
+		return true
+	}
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return false
+	}
+	for _, src := range s.ann.Sources() {
+		if src.Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// goFile returns true if path is a Go file annotations are parsed from, test files are ignored
+func goFile(path string) bool {
+	name := filepath.Base(path)
+	return !strings.HasPrefix(name, ".") &&
+		!strings.HasSuffix(name, "_test.go") &&
+		strings.HasSuffix(name, ".go")
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+)
+
+func TestSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	foo := write("foo.go", `package foo
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get
+type Foo struct{}
+`)
+	consts := write("consts.go", `package foo
+
+const Group = "apps"
+`)
+	bar := write("bar.go", `package foo
+
+// +kubebuilder:webhook:admission:groups=${Group},resources=deployments
+type Bar struct{}
+`)
+	write("foo_test.go", "package foo\n\n// +kubebuilder:rbac:groups=test\ntype T struct{}\n")
+
+	ann := annotation.Build()
+	ann.Header("kubebuilder")
+	nop := func(string) error { return nil }
+	ann.Module(&annotation.Module{Name: "rbac", Do: nop})
+	ann.Module(&annotation.Module{Name: "webhook", SubModules: map[string]*annotation.Module{
+		"admission": &annotation.Module{Name: "admission", Do: nop},
+	}})
+	s := NewSession(ann)
+	if err := s.Load(dir); err != nil {
+		t.Fatalf("Load should have succeeded, but got error: %v", err)
+	}
+	if n := len(s.Instances()); n != 2 {
+		t.Fatalf("expect 2 annotations loaded, got %d", n)
+	}
+
+	// lines moved by edits are not reported
+	write("foo.go", `package foo
+
+// Foo is foo
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get
+// +kubebuilder:rbac:groups=apps,resources=deployments/status,verbs=get
+type Foo struct{}
+`)
+	c := s.Update([]string{foo, filepath.Join(dir, "README.md")})
+	if !reflect.DeepEqual(c.Files, []string{bar, foo}) {
+		t.Errorf("expect changed file and file referencing constants re-scanned, got %v", c.Files)
+	}
+	exp := "2 files changed, 1 annotations added, 0 removed\n" +
+		"+ " + foo + ":5: +kubebuilder:rbac:groups=apps,resources=deployments/status,verbs=get (Foo)\n"
+	if c.Summary() != exp {
+		t.Errorf("expect summary %q, got %q", exp, c.Summary())
+	}
+	if !c.Affects("rbac") || c.Affects("webhook") {
+		t.Errorf("expect change affecting rbac only")
+	}
+
+	// constants declared in other files are resolved again
+	write("consts.go", "package foo\n\nconst Group = \"batch\"\n")
+	c = s.Update([]string{consts})
+	if len(c.Added) != 1 || c.Added[0].Text != "+kubebuilder:webhook:admission:groups=batch,resources=deployments" || len(c.Removed) != 1 {
+		t.Errorf("expect annotation of changed constant replaced, got %s", c.Summary())
+	}
+	if !c.Affects("webhook") || c.Affects("rbac") {
+		t.Errorf("expect change affecting webhook only")
+	}
+
+	// files failing to scan keep their annotations, including files resolving constants of broken package
+	write("foo.go", "package foo\n\ntype Foo struct{\n")
+	if c = s.Update([]string{foo}); len(c.Errors) != 2 || !c.Empty() {
+		t.Errorf("expect error of broken file without change, got %s", c.Summary())
+	}
+	if err := os.Remove(foo); err != nil {
+		t.Fatal(err)
+	}
+	if c = s.Update([]string{foo}); len(c.Removed) != 2 || len(c.Errors) != 0 {
+		t.Errorf("expect annotations of removed file removed, got %s", c.Summary())
+	}
+
+	// files read by sources of the registry are parsed
+	ann.Source(annotation.YAMLSource{})
+	rbac := write("rbac.yaml", "# +kubebuilder:rbac:groups=apps,resources=deployments,verbs=list\nkind: Role\n")
+	if c = s.Update([]string{rbac}); len(c.Added) != 1 || c.Added[0].Target != "Role" {
+		t.Errorf("expect annotation of YAML file added, got %s", c.Summary())
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("watching directories requires Linux inotify")
+	}
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := NewWatcher(dir)
+	if err != nil {
+		t.Fatalf("NewWatcher should have succeeded, but got error: %v", err)
+	}
+	defer w.Close()
+	w.Debounce = 50 * time.Millisecond
+
+	foo := filepath.Join(dir, "foo.go")
+	sub := filepath.Join(dir, "sub")
+	go func() {
+		for n := 0; n < 3; n++ {
+			ioutil.WriteFile(foo, []byte("package foo\n"), 0644)
+			time.Sleep(10 * time.Millisecond)
+		}
+		ioutil.WriteFile(filepath.Join(dir, "foo_test.go"), []byte("package foo\n"), 0644)
+		os.Mkdir(sub, 0755)
+		ioutil.WriteFile(filepath.Join(sub, "bar.go"), []byte("package sub\n"), 0644)
+	}()
+	paths, err := w.Next()
+	if err != nil {
+		t.Fatalf("Next should have succeeded, but got error: %v", err)
+	}
+	exp := []string{foo, filepath.Join(sub, "bar.go")}
+	if !reflect.DeepEqual(paths, exp) {
+		t.Errorf("expect rapid changes reported once as %v, got %v", exp, paths)
+	}
+}
@@ -31,15 +31,17 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
 )
 
 var decoder = scheme.Codecs.UniversalDeserializer()
 
-// NewManifestClient constructs a new manifestClient.
+// NewManifestClient constructs a new manifestClient reading and writing file of annotation.Fs.
 func NewManifestClient(file string) client.Client {
 	return &manifestClient{
 		ManifestFile: file,
-		fs:           afero.NewOsFs(),
+		fs:           annotation.Fs(),
 	}
 }
 
@@ -65,6 +67,8 @@ func (c *manifestClient) index() error {
 	if err != nil {
 		return err
 	}
+	// header is written again after the objects, see annotation.WithGeneratedHeader
+	b = annotation.StripGeneratedHeader(b)
 	objs := bytes.Split(b, []byte("---\n"))
 	for _, objectB := range objs {
 		objB := bytes.TrimSpace(objectB)
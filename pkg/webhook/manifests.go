@@ -19,7 +19,6 @@ package webhook
 import (
 	"bytes"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -27,8 +26,11 @@ import (
 	"text/template"
 
 	"github.com/ghodss/yaml"
+	"github.com/spf13/afero"
 
 	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/config"
+	"github.com/fanzhangio/go-annotation/pkg/resource"
 	"github.com/fanzhangio/go-annotation/pkg/webhook/internal"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -38,6 +40,10 @@ type ManifestOptions struct {
 	InputDir       string
 	OutputDir      string
 	PatchOutputDir string
+	// APIsDir is the directory of API types, resources of webhooks are checked against resources declared there
+	APIsDir string
+	// Features are feature flags enabled for annotations conditional on them, see annotation.ConditionKey
+	Features []string
 
 	webhooks []webhook.Webhook
 	svrOps   *webhook.ServerOptions
@@ -45,6 +51,9 @@ type ManifestOptions struct {
 
 	webhookKVMap map[string]string
 	serverKVMap  map[string]string
+
+	// project is the project file applied to registry of every run
+	project *config.Config
 }
 
 // SetDefaults sets up the default options for RBAC Manifest generator.
@@ -52,28 +61,96 @@ func (o *ManifestOptions) SetDefaults() {
 	o.InputDir = filepath.Join(".", "pkg", "webhook")
 	o.OutputDir = filepath.Join(".", "config", "webhook")
 	o.PatchOutputDir = filepath.Join(".", "config", "default")
+	o.APIsDir = filepath.Join(".", "pkg", "apis")
 	o.webhookKVMap = map[string]string{}
 	o.serverKVMap = map[string]string{}
+	o.svrOps = &webhook.ServerOptions{}
+}
+
+// ApplyConfig overrides defaults by options of "webhook" generator and features in project file.
+// Option "patchOutputDir" is the directory of the label patch of manager, and "apisDir" is the directory of API types.
+func (o *ManifestOptions) ApplyConfig(c *config.Config) error {
+	o.project = c
+	g := c.Generator("webhook")
+	if err := g.CheckOptions("webhook", "patchOutputDir", "apisDir"); err != nil {
+		return err
+	}
+	if len(g.InputDir) > 0 {
+		o.InputDir = g.InputDir
+	}
+	if len(g.OutputDir) > 0 {
+		o.OutputDir = g.OutputDir
+	}
+	if dir, ok := g.Option("patchOutputDir"); ok {
+		o.PatchOutputDir = dir
+	}
+	if dir, ok := g.Option("apisDir"); ok {
+		o.APIsDir = dir
+	}
+	if len(c.Features) > 0 {
+		o.Features = c.Features
+	}
+	return nil
+}
+
+// Inputs returns hash of webhook annotations in the input directory and features, which is recorded in headers of
+// the manifests, see annotation.InputsHash.
+func (o *ManifestOptions) Inputs() (string, error) {
+	return o.inputs(o.AddToAnnotation(o.registry()))
+}
+
+// registry returns registry of a single run with default headers, the project file and features applied, so modules,
+// hooks and features of the run don't leak into other runs
+func (o *ManifestOptions) registry() annotation.Annotation {
+	ann := annotation.AddDefaults(annotation.Build())
+	if o.project != nil {
+		o.project.Apply(ann)
+	}
+	ann.Features(o.Features...)
+	return ann
+}
+
+func (o *ManifestOptions) inputs(ann annotation.Annotation) (string, error) {
+	return annotation.InputsHash(o.InputDir, ann, []string{"webhook"}, "features="+strings.Join(o.Features, ","))
 }
 
 // Validate validates the input options.
 func (o *ManifestOptions) Validate() error {
-	if _, err := os.Stat(o.InputDir); err != nil {
+	if _, err := annotation.Fs().Stat(o.InputDir); err != nil {
 		return fmt.Errorf("invalid input directory '%s' %v", o.InputDir, err)
 	}
 	return nil
 }
 
+// parse parses webhook annotations in the input directory and resources of the APIs directory as a single run by
+// registry of the run, resources of webhooks are checked when the run finishes. Other modules of API types are
+// disabled in the run, they are left to generators of APIs.
+func (o *ManifestOptions) parse() (annotation.Annotation, error) {
+	ann := o.AddToAnnotation(o.registry())
+	dirs := []string{o.InputDir}
+	if _, err := annotation.Fs().Stat(o.APIsDir); len(o.APIsDir) > 0 && err == nil {
+		dirs = append(dirs, o.APIsDir)
+		for _, m := range resource.TypeModules {
+			ann.Disable(m)
+		}
+	}
+	if err := annotation.ParseAnnotationByDirs(dirs, ann); err != nil {
+		return nil, fmt.Errorf("failed to parse the input dir: %v", err)
+	}
+	return ann, nil
+}
+
 // Generate generates RBAC manifests by parsing the RBAC annotations in Go source
-// files specified in the input directory.
+// files specified in the input directory. Files are read from and written to annotation.Fs,
+// and the manifests carry header recording hash of inputs, see Inputs.
 func Generate(o *ManifestOptions) error {
 	if err := o.Validate(); err != nil {
 		return err
 	}
 
-	_, err := os.Stat(o.OutputDir)
+	_, err := annotation.Fs().Stat(o.OutputDir)
 	if os.IsNotExist(err) {
-		err = os.MkdirAll(o.OutputDir, 0766)
+		err = annotation.Fs().MkdirAll(o.OutputDir, 0766)
 		if err != nil {
 			return err
 		}
@@ -86,9 +163,9 @@ func Generate(o *ManifestOptions) error {
 		Client: internal.NewManifestClient(path.Join(o.OutputDir, "webhook.yaml")),
 	}
 	// parse webhook annotation by generic annotation approach
-	err = annotation.ParseAnnotationByDir(o.InputDir, o.AddToAnnotation(annotation.GetAnnotation()))
+	ann, err := o.parse()
 	if err != nil {
-		return fmt.Errorf("failed to parse the input dir: %v", err)
+		return err
 	}
 
 	o.svr, err = webhook.NewServer("generator", &internal.Manager{}, *o.svrOps)
@@ -104,11 +181,24 @@ func Generate(o *ManifestOptions) error {
 	if err != nil {
 		return err
 	}
+	inputs, err := o.inputs(ann)
+	if err != nil {
+		return fmt.Errorf("failed to hash the input dir: %v", err)
+	}
+	manifestFile := path.Join(o.OutputDir, "webhook.yaml")
+	manifest, err := afero.ReadFile(annotation.Fs(), manifestFile)
+	if err == nil {
+		err = afero.WriteFile(annotation.Fs(), manifestFile, annotation.WithGeneratedHeader(manifest, inputs), 0666)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	annotation.Log().V(1).Info("wrote webhook manifests", "dir", o.OutputDir, "webhooks", len(o.webhooks))
 
-	return o.labelPatch()
+	return o.labelPatch(inputs)
 }
 
-func (o *ManifestOptions) labelPatch() error {
+func (o *ManifestOptions) labelPatch(inputs string) error {
 	var kustomizeLabelPatch = `apiVersion: apps/v1
 kind: StatefulSet
 metadata:
@@ -139,7 +229,8 @@ spec:
 	if err := temp.Execute(buf, p); err != nil {
 		return err
 	}
-	return ioutil.WriteFile(path.Join(o.PatchOutputDir, "manager_label_patch.yaml"), buf.Bytes(), 0644)
+	patch := annotation.WithGeneratedHeader(buf.Bytes(), inputs)
+	return afero.WriteFile(annotation.Fs(), path.Join(o.PatchOutputDir, "manager_label_patch.yaml"), patch, 0644)
 }
 
 func toYAML(m map[string]string) (string, error) {
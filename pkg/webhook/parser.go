@@ -19,17 +19,17 @@ package webhook
 import (
 	"errors"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 
+	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/fanzhangio/go-annotation/pkg/resource"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	webhooktypes "sigs.k8s.io/controller-runtime/pkg/webhook/types"
-	
 )
 
 var (
@@ -37,33 +37,100 @@ var (
 	serverTags  = sets.NewString([]string{"port", "cert-dir", "service", "selector", "secret", "host", "mutating-webhook-config-name", "validating-webhook-config-name"}...)
 )
 
-// AddToAnnotation registers webhook module into kubebuilder Annotation
+// AddToAnnotation registers webhook module into kubebuilder Annotation.
+// Resource module is registered as well if missing, since resources of webhooks are checked against its result
+// when the run finishes.
 func (o *ManifestOptions) AddToAnnotation(a annotation.Annotation) annotation.Annotation {
+	if !a.HasModule("resource") {
+		resource.NewResources(nil).AddToAnnotation(a)
+	}
 	a.Module(&annotation.Module{
-		Name: "webhook",
-		Do:   nil,
+		Name:     "webhook",
+		Do:       nil,
+		Requires: []string{"resource"},
+		Hooks: annotation.Hooks{
+			OnFinish: func() error {
+				var res *resource.Resources
+				if err := annotation.Result(a, "resource", &res); err != nil {
+					return err
+				}
+				return o.checkResources(res.ByGroup)
+			},
+		},
 		SubModules: map[string]*annotation.Module{
 			"admission": &annotation.Module{
 				Name:       "admission",
 				SubModules: map[string]*annotation.Module{},
 				Do:         o.admissionFunc,
+				Doc:        "admission webhook served by the manager",
+				Params: []annotation.Param{
+					{Name: "groups", Doc: "API groups of the rule split by semicolon"},
+					{Name: "versions", Doc: "API versions of the rule split by semicolon"},
+					{Name: "resources", Doc: "resources of the rule split by semicolon"},
+					{Name: "verbs", Doc: "operations of the rule split by semicolon", Values: []string{"create", "update", "delete", "connect", "*"}},
+					{Name: "type", Doc: "type of the webhook", Values: []string{"mutating", "validating"}},
+					{Name: "name", Doc: "name of the webhook"},
+					{Name: "path", Doc: "path the webhook is served at"},
+					{Name: "failure-policy", Doc: "how unrecognized errors from the webhook are handled", Values: []string{"fail", "ignore"}},
+				},
 			},
 			"serveroption": &annotation.Module{
-				Name:       "serveroption",
-				SubModules: map[string]*annotation.Module{},
-				Do:         o.serverOptionFunc,
+				Name:        "serveroption",
+				SubModules:  map[string]*annotation.Module{},
+				Do:          o.serverOptionFunc,
+				Doc:         "options of the webhook server",
+				Cardinality: annotation.OncePerRun,
+				Merge:       annotation.MergeKeys,
+				Params: []annotation.Param{
+					{Name: "port", Doc: "port the webhook server listens on"},
+					{Name: "cert-dir", Doc: "directory of the server certificates"},
+					{Name: "service", Doc: "service of the webhook server, formatted as namespace|name"},
+					{Name: "selector", Doc: "selector of the service, formatted as label1|value1;label2|value2"},
+					{Name: "secret", Doc: "secret of the server certificates, formatted as namespace|name"},
+					{Name: "host", Doc: "host of the webhook server"},
+					{Name: "mutating-webhook-config-name", Doc: "name of the MutatingWebhookConfiguration"},
+					{Name: "validating-webhook-config-name", Doc: "name of the ValidatingWebhookConfiguration"},
+				},
 			},
 		},
+		Doc: "admission webhooks and webhook server options, e.g. +kubebuilder:webhook:admission:... or +kubebuilder:webhook:serveroption:...",
 	})
 	return a
 }
 
+// checkResources checks resources of webhooks against resources declared by +kubebuilder:resource in the run by full
+// group name, e.g. ship.example.com. Only groups having resources declared are checked, e.g. core groups are skipped.
+func (o *ManifestOptions) checkResources(byGroup map[string]sets.String) error {
+	for _, wh := range o.webhooks {
+		w, ok := wh.(*admission.Webhook)
+		if !ok {
+			continue
+		}
+		for _, rule := range w.Rules {
+			for _, group := range rule.APIGroups {
+				declared, ok := byGroup[group]
+				if !ok {
+					continue
+				}
+				for _, resource := range rule.Resources {
+					// subresources are checked by their resources, e.g. foos/status
+					resource = strings.SplitN(resource, "/", 2)[0]
+					if resource != "*" && !declared.Has(resource) {
+						return fmt.Errorf("webhook %s: resource %s of group %s is not declared by +kubebuilder:resource", w.Name, resource, group)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // admissionFunc is hanlder for webhook admission submodule
 func (o *ManifestOptions) admissionFunc(commentText string) error {
 	for _, elem := range strings.Split(commentText, ",") {
 		key, value, err := annotation.ParseKV(elem)
 		if err != nil {
-			log.Fatalf("// +kubebuilder:webhook: tags must be key value pairs. Example "+
+			return fmt.Errorf("// +kubebuilder:webhook: tags must be key value pairs. Example "+
 				"keys [groups=<group1;group2>,resources=<resource1;resource2>,verbs=<verb1;verb2>] "+
 				"Got string: [%s]", commentText)
 		}
@@ -78,7 +145,7 @@ func (o *ManifestOptions) serverOptionFunc(commentText string) error {
 	for _, elem := range strings.Split(commentText, ",") {
 		key, value, err := annotation.ParseKV(elem)
 		if err != nil {
-			log.Fatalf("// +kubebuilder:webhook: tags must be key value pairs. Example "+
+			return fmt.Errorf("// +kubebuilder:webhook: tags must be key value pairs. Example "+
 				"keys [groups=<group1;group2>,resources=<resource1;resource2>,verbs=<verb1;verb2>] "+
 				"Got string: [%s]", commentText)
 		}
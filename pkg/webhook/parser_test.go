@@ -17,11 +17,14 @@ limitations under the License.
 package webhook
 
 import (
+	"fmt"
 	"go/token"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/fanzhangio/go-annotation/pkg/annotation"
+	"github.com/spf13/afero"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -174,3 +177,55 @@ func TestParseWebhookServer(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckResources(t *testing.T) {
+	annotation.SetFs(afero.NewMemMapFs())
+	defer annotation.SetFs(nil)
+	apiFile := `package v1
+
+// +genclient:nonNamespaced
+// +kubebuilder:resource:path=%s
+// +kubebuilder:categories:ship
+type Foo struct{}
+`
+	webhookFile := `package webhook
+
+// +kubebuilder:webhook:admission:groups=%s,resources=%s,verbs=create,name=ship-webhook,path=/ship,type=mutating,failure-policy=fail
+func ship() {}
+`
+	generate := func(dir, declared, group, resource string) error {
+		files := map[string]string{
+			dir + "/pkg/apis/doc.go":           "// +domain=example.com\npackage apis\n",
+			dir + "/pkg/apis/ship/v1/types.go": fmt.Sprintf(apiFile, declared),
+			dir + "/pkg/webhook/ship.go":       fmt.Sprintf(webhookFile, group, resource),
+		}
+		for name, content := range files {
+			if err := afero.WriteFile(annotation.Fs(), name, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		o := &ManifestOptions{}
+		o.SetDefaults()
+		o.InputDir = dir + "/pkg/webhook"
+		o.APIsDir = dir + "/pkg/apis"
+		o.webhooks = []webhook.Webhook{}
+		_, err := o.parse()
+		return err
+	}
+
+	// generations in the same process check webhooks of their own run only
+	if err := generate("/foo", "foos", "ship.example.com", "foos"); err != nil {
+		t.Errorf("generation of declared resource should have succeeded, but got error: %v", err)
+	}
+	if err := generate("/bar", "bars", "ship.example.com", "bars"); err != nil {
+		t.Errorf("generation of declared resource should have succeeded, but got error: %v", err)
+	}
+	exp := "webhook ship-webhook: resource bazs of group ship.example.com is not declared by +kubebuilder:resource"
+	if err := generate("/baz", "foos", "ship.example.com", "bazs"); err == nil || !strings.Contains(err.Error(), exp) {
+		t.Errorf("expect error %q, got %v", exp, err)
+	}
+	// groups are matched by full names, groups of other domains are not checked
+	if err := generate("/qux", "foos", "ship.example.org", "bazs"); err != nil {
+		t.Errorf("generation of resource in other domain should have succeeded, but got error: %v", err)
+	}
+}